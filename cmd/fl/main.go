@@ -30,6 +30,11 @@ func main() {
 	}
 	utils.InitStyles(stylesConfig)
 
+	if err := utils.LoadArchivesConfig(); err != nil {
+		fmt.Printf("Ошибка загрузки настроек архивов: %v\n", err)
+		return
+	}
+
 	p := tea.NewProgram(service.InitialModel(), tea.WithAltScreen())
 	m, err := p.Run()
 	if err != nil {
@@ -38,18 +43,7 @@ func main() {
 	}
 
 	if mdl, ok := m.(*service.FileManagerState); ok {
-		if mdl.ArchiveReader != nil {
-			mdl.ArchiveReader.Close()
-		}
-		if mdl.RemoteArchiveFile != nil {
-			mdl.RemoteArchiveFile.Close()
-		}
-		if mdl.SftpClient != nil {
-			mdl.SftpClient.Close()
-		}
-		if mdl.SftpSession != nil {
-			mdl.SftpSession.Close()
-		}
+		mdl.Close()
 		if mdl.ExitWithDir {
 			fmt.Printf("cd %s\n", mdl.Cwd)
 		}
@@ -1,15 +1,28 @@
 package models
 
 import (
+	"time"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
 // ===================== Константы и глобальные переменные =====================
 
 const (
-	ConfigFileName = ".filemanager/sftp_config.json"
-	DownloadDir    = ".filemanager/downloads"
-	StylesFile     = ".filemanager/filemanager_styles.json"
+	ConfigFileName   = ".filemanager/sftp_config.json"
+	DownloadDir      = ".filemanager/downloads"
+	StylesFile       = ".filemanager/filemanager_styles.json"
+	ArchivesFile     = ".filemanager/archives.json"
+	S3ProfilesFile   = ".filemanager/s3_profiles.json"
+	SFTPProfilesFile = ".filemanager/sftp_profiles.json"
+	VaultFile        = ".filemanager/vault.json"
+	TransfersFile    = ".filemanager/transfers.json"
+
+	// Максимальный объём распакованных данных для превью сжатых файлов
+	MaxDecompressedPreview = 1024 * 1024
+	// Максимальный объём, читаемый для превью обычного файла: с удалённых
+	// файловых систем (например, S3) файл не скачивается целиком
+	MaxPreviewSize = 1024 * 1024
 )
 
 var (
@@ -43,15 +56,88 @@ var (
 		".db", ".sqlite", ".mdb", ".accdb",
 		".torrent", ".ttf",
 	}
+	// Расширения zip-контейнеров, которые открываются как директории.
+	// Список можно переопределить в ~/.filemanager/archives.json.
+	ZipExtensions = []string{
+		".zip", ".jar", ".war", ".ear", ".aar", ".apk", ".ipa",
+		".whl", ".nupkg", ".vsix", ".xpi", ".epub",
+		".docx", ".xlsx", ".pptx", ".odt", ".ods", ".odp",
+	}
+	// Пакеты, которые открываются как директории только для чтения
+	PackageExtensions = []string{".deb", ".rpm"}
+	// Образы дисков, которые открываются как директории только для чтения
+	DiskImageExtensions = []string{".iso"}
+	// Расширения tar-архивов, которые открываются как директории
+	TarExtensions = []string{
+		".tar", ".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tbz", ".tar.xz", ".txz", ".tar.zst", ".tzst",
+	}
 )
 
 // ===================== Структуры данных =====================
 
-// SFTPConfig хранит конфигурацию SFTP подключения
+// SFTPConfig хранит общие настройки SSH. Поля Host, User и Password остались
+// от единственного сохранённого подключения и переносятся в профили.
 type SFTPConfig struct {
-	Host     string `json:"host"`
-	User     string `json:"user"`
-	Password string `json:"password"`
+	Host     string `json:"host,omitempty"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Закрытые ключи; по умолчанию ~/.ssh/id_ed25519, id_ecdsa и id_rsa
+	IdentityFiles []string `json:"identityFiles,omitempty"`
+	// Порядок способов входа: agent, publickey, password
+	AuthMethods []string `json:"authMethods,omitempty"`
+	// Переходы до сервера в формате ProxyJump: [user@]host[:port],...
+	ProxyJump string `json:"proxyJump,omitempty"`
+	// Хранение паролей: vault (зашифрованное хранилище, по умолчанию) или none
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// SFTPProfile — именованное сохранённое подключение
+type SFTPProfile struct {
+	Name         string `json:"name"`
+	Host         string `json:"host"` // хост, алиас ssh или адрес со схемой (ftp://, dav://)
+	Port         int    `json:"port,omitempty"`
+	User         string `json:"user,omitempty"`
+	Password     string `json:"password,omitempty"`   // открытым текстом от прежних версий; переносится в хранилище
+	AuthMethod   string `json:"authMethod,omitempty"` // auto, password, publickey или agent
+	IdentityFile string `json:"identityFile,omitempty"`
+	Dir          string `json:"dir,omitempty"` // начальная директория
+	ProxyJump    string `json:"proxyJump,omitempty"`
+	LastDir      string `json:"lastDir,omitempty"` // директория на момент отключения
+}
+
+// TransferJob — задача очереди передач. Скачивание переносит файл Source
+// с сервера в локальный файл Dest, загрузка — локальный файл или директорию
+// Source в директорию Dest на сервере.
+type TransferJob struct {
+	Kind      string `json:"kind"`  // download или upload
+	State     string `json:"state"` // pending, active, paused, failed или done
+	Host      string `json:"host"`  // адрес подключения, на котором выполняется задача
+	Source    string `json:"source"`
+	Dest      string `json:"dest"`
+	Size      int64  `json:"size"`
+	Done      int64  `json:"done"`
+	Overwrite bool   `json:"overwrite,omitempty"` // заменять существующие файлы на сервере
+	Error     string `json:"error,omitempty"`
+
+	// Размер и время изменения файла на сервере при запуске скачивания:
+	// недокачанный файл продолжается, только если источник не изменился
+	SourceSize    int64     `json:"sourceSize,omitempty"`
+	SourceModTime time.Time `json:"sourceModTime,omitzero"`
+}
+
+// S3Profile хранит параметры подключения к S3-совместимому хранилищу
+type S3Profile struct {
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint"` // host[:port]
+	Region    string `json:"region,omitempty"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey,omitempty"` // открытым текстом от прежних версий; переносится в хранилище
+	Secure    bool   `json:"secure"`              // HTTPS
+}
+
+// ArchivesConfig хранит настройки распознавания архивов
+type ArchivesConfig struct {
+	ZipExtensions []string `json:"zipExtensions"`
 }
 
 type StylesConfig struct {
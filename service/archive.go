@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// ===================== Стек архивов =====================
+
+// archiveLayer — один уровень вложенности архивов
+type archiveLayer struct {
+	fs         vfs.FileSystem
+	path       string // путь к архиву в родительской файловой системе
+	dir        string // текущая директория внутри архива
+	prevCursor int    // позиция курсора в родительской директории
+
+	mu      sync.Mutex
+	users   int  // число фоновых задач, читающих архив
+	closing bool // архив закрыт в интерфейсе и ждёт завершения задач
+}
+
+func (l *archiveLayer) acquire() {
+	l.mu.Lock()
+	l.users++
+	l.mu.Unlock()
+}
+
+func (l *archiveLayer) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.users--
+	if l.users == 0 && l.closing {
+		l.fs.Close()
+	}
+}
+
+// close закрывает архив сразу или после завершения использующих его задач
+func (l *archiveLayer) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closing = true
+	if l.users == 0 {
+		l.fs.Close()
+	}
+}
+
+func (m *FileManagerState) inArchive() bool {
+	return len(m.archives) > 0
+}
+
+func (m *FileManagerState) topArchive() *archiveLayer {
+	if len(m.archives) == 0 {
+		return nil
+	}
+	return m.archives[len(m.archives)-1]
+}
+
+// pushArchive открывает архив name из текущей директории как новый уровень
+func (m *FileManagerState) pushArchive(name string) error {
+	archivePath := m.entryPath(name)
+	archive, err := vfs.OpenArchive(m.activeFS(), archivePath)
+	if err != nil {
+		return err
+	}
+
+	m.cursorPositions[m.displayPath()] = m.cursor
+	m.marked = make(map[string]bool)
+	m.archives = append(m.archives, &archiveLayer{
+		fs:         archive,
+		path:       archivePath,
+		prevCursor: m.cursor,
+	})
+	return nil
+}
+
+// popArchive закрывает верхний уровень архива и возвращает его
+func (m *FileManagerState) popArchive() *archiveLayer {
+	layer := m.topArchive()
+	if layer == nil {
+		return nil
+	}
+	layer.close()
+	m.archives = m.archives[:len(m.archives)-1]
+	m.marked = make(map[string]bool)
+	return layer
+}
+
+// holdArchives не даёт закрыть открытые архивы, пока их читает фоновая задача.
+// Возвращает функцию освобождения.
+func (m *FileManagerState) holdArchives() func() {
+	layers := slices.Clone(m.archives)
+	for _, layer := range layers {
+		layer.acquire()
+	}
+	return func() {
+		for _, layer := range layers {
+			layer.release()
+		}
+	}
+}
+
+// checkArchiveBusy запрещает изменять архив, который читает фоновая задача
+func (m *FileManagerState) checkArchiveBusy() error {
+	for _, layer := range m.archives {
+		layer.mu.Lock()
+		busy := layer.users > 0
+		layer.mu.Unlock()
+		if busy {
+			return errors.New("архив используется фоновой задачей")
+		}
+	}
+	return nil
+}
+
+// closeArchives закрывает все открытые архивы от внутреннего к внешнему
+func (m *FileManagerState) closeArchives() {
+	for m.inArchive() {
+		m.popArchive()
+	}
+}
+
+// archiveDisplayPath собирает путь вида /dir/outer.zip/inner/nested.tar.gz/dir
+func (m *FileManagerState) archiveDisplayPath() string {
+	var p string
+	for i, layer := range m.archives {
+		if i == 0 {
+			p = layer.path
+		} else {
+			p = filepath.Join(p, filepath.FromSlash(layer.path))
+		}
+	}
+	return filepath.Join(p, filepath.FromSlash(path.Clean("/"+m.topArchive().dir)))
+}
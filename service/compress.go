@@ -0,0 +1,225 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Создание архивов =====================
+
+// compressJob описывает упаковку файлов текущей файловой системы в архив
+type compressJob struct {
+	fsys    vfs.FileSystem
+	sources []string
+	dest    string
+}
+
+// compressItem — один файл или директория, попадающие в архив
+type compressItem struct {
+	src  string
+	name string
+	info os.FileInfo
+}
+
+// startCompress запрашивает имя архива для отмеченных элементов или текущего элемента
+func (m *FileManagerState) startCompress() (tea.Model, tea.Cmd) {
+	if m.inArchive() {
+		m.status = "Создание архива внутри архива не поддерживается"
+		return m, nil
+	}
+	if len(m.files) == 0 {
+		return m, nil
+	}
+
+	name := m.files[m.cursor].Name()
+	if len(m.marked) > 1 {
+		name = filepath.Base(m.Cwd)
+	}
+	m.mode = "compress"
+	m.input = name + ".zip"
+	return m, nil
+}
+
+// runCompress проверяет имя архива и запускает упаковку в фоне
+func (m *FileManagerState) runCompress(name string) (tea.Model, tea.Cmd) {
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, ".zip") && !strings.HasSuffix(lower, ".tar.gz") && !strings.HasSuffix(lower, ".tgz") {
+		m.status = "Поддерживаются архивы .zip и .tar.gz"
+		return m, nil
+	}
+
+	job := &compressJob{
+		fsys:    m.fs,
+		sources: m.markedPaths(),
+		dest:    filepath.Join(m.Cwd, name),
+	}
+	if len(job.sources) == 0 {
+		job.sources = []string{filepath.Join(m.Cwd, m.files[m.cursor].Name())}
+	}
+	if _, err := job.fsys.Stat(job.dest); err == nil {
+		m.status = fmt.Sprintf("Файл %s уже существует", name)
+		return m, nil
+	}
+
+	m.mode = "normal"
+	m.input = ""
+	m.marked = make(map[string]bool)
+	return m, startTask(job.run)
+}
+
+func (job *compressJob) run(progress func(string)) (string, error) {
+	var items []compressItem
+	var total, done int64
+	for _, src := range job.sources {
+		parent := filepath.Dir(src)
+		err := vfs.Walk(job.fsys, src, func(name string, info os.FileInfo) error {
+			rel, err := filepath.Rel(parent, name)
+			if err != nil {
+				return err
+			}
+			items = append(items, compressItem{src: name, name: filepath.ToSlash(rel), info: info})
+			if info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("ошибка чтения %s: %v", src, err)
+		}
+	}
+
+	// Архив пишется во временный файл и переименовывается только после успешной записи
+	tmpPath := job.dest + ".part"
+	out, err := job.fsys.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания архива: %v", err)
+	}
+
+	written := func(n int64) {
+		done += n
+		progress(fmt.Sprintf("Сжатие %s: %d%%", filepath.Base(job.dest), percent(done, total)))
+	}
+	if strings.HasSuffix(strings.ToLower(job.dest), ".zip") {
+		err = job.writeZip(out, items, written)
+	} else {
+		err = job.writeTarGz(out, items, written)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		job.fsys.Remove(tmpPath)
+		return "", err
+	}
+	if err := job.fsys.Rename(tmpPath, job.dest); err != nil {
+		job.fsys.Remove(tmpPath)
+		return "", fmt.Errorf("ошибка сохранения архива: %v", err)
+	}
+
+	return fmt.Sprintf("Архив %s создан, файлов: %d", filepath.Base(job.dest), len(items)), nil
+}
+
+func (job *compressJob) writeZip(out io.Writer, items []compressItem, written func(int64)) error {
+	zw := zip.NewWriter(out)
+	for _, item := range items {
+		header, err := zip.FileInfoHeader(item.info)
+		if err != nil {
+			return err
+		}
+		header.Name = item.name
+		if item.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("ошибка записи архива: %v", err)
+		}
+		switch {
+		case item.info.Mode()&os.ModeSymlink != 0:
+			link, err := job.fsys.ReadLink(item.src)
+			if err != nil {
+				return fmt.Errorf("ошибка чтения ссылки %s: %v", item.src, err)
+			}
+			if _, err := io.WriteString(w, link); err != nil {
+				return fmt.Errorf("ошибка записи архива: %v", err)
+			}
+		case item.info.Mode().IsRegular():
+			if err := job.copyFile(w, item.src, written); err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+func (job *compressJob) writeTarGz(out io.Writer, items []compressItem, written func(int64)) error {
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	for _, item := range items {
+		var link string
+		if item.info.Mode()&os.ModeSymlink != 0 {
+			var err error
+			if link, err = job.fsys.ReadLink(item.src); err != nil {
+				return fmt.Errorf("ошибка чтения ссылки %s: %v", item.src, err)
+			}
+		}
+		header, err := tar.FileInfoHeader(item.info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = item.name
+		if item.info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("ошибка записи архива: %v", err)
+		}
+		if item.info.Mode().IsRegular() {
+			if err := job.copyFile(tw, item.src, written); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyFile дописывает содержимое файла src в архив
+func (job *compressJob) copyFile(w io.Writer, src string, written func(int64)) error {
+	f, err := job.fsys.Open(src)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия %s: %v", src, err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("ошибка записи архива: %v", err)
+			}
+			written(int64(n))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка чтения %s: %v", src, err)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/utils"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Распаковка архивов =====================
+
+// extractJob описывает распаковку записей архива на локальный диск
+type extractJob struct {
+	fsys      vfs.FileSystem
+	sources   []string
+	target    string
+	overwrite bool
+	items     []extractItem
+	closeFS   bool   // архив открыт только для распаковки и закрывается после неё
+	release   func() // освобождает архивы интерфейса, которые читает задача
+}
+
+// extractItem — один файл или директория плана распаковки
+type extractItem struct {
+	src  string
+	dest string
+	info os.FileInfo
+}
+
+// startExtract готовит распаковку выделенных записей, текущей записи
+// или всего архива и запрашивает директорию назначения
+func (m *FileManagerState) startExtract(whole bool) (tea.Model, tea.Cmd) {
+	job := &extractJob{}
+	var name string
+
+	switch {
+	case m.inArchive() && whole:
+		job.fsys = m.activeFS()
+		job.sources = []string{""}
+		name = utils.ArchiveBaseName(filepath.Base(m.topArchive().path))
+	case m.inArchive():
+		if len(m.files) == 0 {
+			return m, nil
+		}
+		job.fsys = m.activeFS()
+		job.sources = m.markedPaths()
+		if len(job.sources) == 0 {
+			job.sources = []string{m.entryPath(m.files[m.cursor].Name())}
+		}
+	case len(m.files) > 0 && vfs.IsArchive(m.fs, filepath.Join(m.Cwd, m.files[m.cursor].Name()), m.files[m.cursor]):
+		selected := m.files[m.cursor].Name()
+		archive, err := vfs.OpenArchive(m.fs, filepath.Join(m.Cwd, selected))
+		if err != nil {
+			return m, tea.Println("Ошибка чтения архива:", err)
+		}
+		job.fsys = archive
+		job.sources = []string{""}
+		job.closeFS = true
+		name = utils.ArchiveBaseName(selected)
+	default:
+		m.status = "Распаковка доступна только для архивов"
+		return m, nil
+	}
+
+	m.pendingExtract = job
+	m.mode = "extract"
+	m.input = filepath.Join(m.localDir(), name)
+	return m, nil
+}
+
+// localDir возвращает локальную директорию по умолчанию для сохранения файлов
+func (m *FileManagerState) localDir() string {
+	if !m.isRemote {
+		return m.Cwd
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// cancelExtract отменяет подготовленную распаковку
+func (m *FileManagerState) cancelExtract() {
+	if m.pendingExtract != nil && m.pendingExtract.closeFS {
+		m.pendingExtract.fsys.Close()
+	}
+	m.pendingExtract = nil
+}
+
+// planExtract составляет список файлов для распаковки в target
+func (m *FileManagerState) planExtract(target string) (tea.Model, tea.Cmd) {
+	job := m.pendingExtract
+	target = expandHome(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(m.localDir(), target)
+	}
+	job.target = filepath.Clean(target)
+
+	conflicts, err := job.plan()
+	if err != nil {
+		m.cancelExtract()
+		m.mode = "normal"
+		return m, tea.Println("Ошибка распаковки:", err)
+	}
+	if conflicts > 0 {
+		m.mode = "extract_overwrite"
+		m.input = ""
+		m.status = fmt.Sprintf("Уже существует файлов: %d", conflicts)
+		return m, nil
+	}
+	return m.runExtract()
+}
+
+// plan обходит исходные записи и сопоставляет им пути внутри target;
+// возвращает число файлов, которые уже существуют
+func (job *extractJob) plan() (int, error) {
+	conflicts := 0
+	job.items = nil
+	for _, src := range job.sources {
+		parent := filepath.Dir(src)
+		err := vfs.Walk(job.fsys, src, func(name string, info os.FileInfo) error {
+			rel, err := filepath.Rel(parent, name)
+			if err != nil {
+				return err
+			}
+			dest, err := safeJoin(job.target, rel)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Lstat(dest); err == nil && !info.IsDir() {
+				conflicts++
+			}
+			job.items = append(job.items, extractItem{src: name, dest: dest, info: info})
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return conflicts, nil
+}
+
+// runExtract запускает распаковку подготовленного плана в фоне
+func (m *FileManagerState) runExtract() (tea.Model, tea.Cmd) {
+	job := m.pendingExtract
+	m.pendingExtract = nil
+	m.mode = "normal"
+	m.input = ""
+	m.marked = make(map[string]bool)
+	if !job.closeFS {
+		job.release = m.holdArchives()
+	}
+	return m, startTask(job.run)
+}
+
+// safeJoin соединяет target и rel, запрещая выход за пределы target (zip-slip)
+func safeJoin(target, rel string) (string, error) {
+	dest := filepath.Join(target, rel)
+	if !within(target, dest) {
+		return "", fmt.Errorf("небезопасный путь в архиве: %s", rel)
+	}
+	return dest, nil
+}
+
+// insideTarget проверяет, что реальный путь dir (с учётом символических ссылок) лежит внутри target
+func insideTarget(target, dir string) bool {
+	realTarget, err := filepath.EvalSymlinks(target)
+	if err != nil {
+		return false
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	return within(realTarget, realDir)
+}
+
+// within проверяет, что очищенный путь p совпадает с target или лежит внутри него
+func within(target, p string) bool {
+	return p == target || strings.HasPrefix(p, target+string(os.PathSeparator))
+}
+
+func (job *extractJob) run(progress func(string)) (string, error) {
+	defer func() {
+		if job.closeFS {
+			job.fsys.Close()
+		}
+		if job.release != nil {
+			job.release()
+		}
+	}()
+
+	var total, done int64
+	for _, item := range job.items {
+		if item.info.Mode().IsRegular() {
+			total += item.info.Size()
+		}
+	}
+
+	if err := os.MkdirAll(job.target, 0755); err != nil {
+		return "", fmt.Errorf("ошибка создания директории: %v", err)
+	}
+
+	extracted, skipped := 0, 0
+	var dirs []extractItem
+	for _, item := range job.items {
+		mode := item.info.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(item.dest, 0755); err != nil {
+				return "", fmt.Errorf("ошибка создания директории: %v", err)
+			}
+			dirs = append(dirs, item)
+			continue
+		case !mode.IsRegular() && mode&os.ModeSymlink == 0:
+			skipped++
+			continue
+		}
+
+		parent := filepath.Dir(item.dest)
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return "", fmt.Errorf("ошибка создания директории: %v", err)
+		}
+		if !insideTarget(job.target, parent) {
+			return "", fmt.Errorf("небезопасный путь в архиве: %s", item.src)
+		}
+
+		if existing, err := os.Lstat(item.dest); err == nil {
+			if !job.overwrite || existing.IsDir() {
+				skipped++
+				done += item.info.Size()
+				continue
+			}
+			os.Remove(item.dest)
+		}
+
+		if mode&os.ModeSymlink != 0 {
+			link, err := job.fsys.ReadLink(item.src)
+			if err != nil || filepath.IsAbs(link) {
+				skipped++
+				continue
+			}
+			if !within(job.target, filepath.Join(filepath.Dir(item.dest), link)) {
+				skipped++
+				continue
+			}
+			if err := os.Symlink(link, item.dest); err != nil {
+				return "", fmt.Errorf("ошибка создания ссылки: %v", err)
+			}
+			extracted++
+			continue
+		}
+
+		if err := job.extractFile(item, func(n int64) {
+			done += n
+			progress(fmt.Sprintf("Распаковка %s: %d%%", filepath.Base(item.dest), percent(done, total)))
+		}); err != nil {
+			return "", err
+		}
+		extracted++
+	}
+
+	// Права и время директорий выставляются в конце, от вложенных к внешним
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i].dest) > len(dirs[j].dest) })
+	for _, dir := range dirs {
+		os.Chmod(dir.dest, dirPerm(dir.info.Mode()))
+		os.Chtimes(dir.dest, dir.info.ModTime(), dir.info.ModTime())
+	}
+
+	status := fmt.Sprintf("Распаковано файлов: %d в %s", extracted, job.target)
+	if skipped > 0 {
+		status += fmt.Sprintf(", пропущено: %d", skipped)
+	}
+	return status, nil
+}
+
+// extractFile копирует одну запись архива, сохраняя права и время изменения
+func (job *extractJob) extractFile(item extractItem, written func(int64)) error {
+	src, err := job.fsys.Open(item.src)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия %s: %v", item.src, err)
+	}
+	defer src.Close()
+
+	perm := item.info.Mode().Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	dst, err := os.OpenFile(item.dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("ошибка создания файла: %v", err)
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				dst.Close()
+				return fmt.Errorf("ошибка записи в файл: %v", err)
+			}
+			written(int64(n))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			dst.Close()
+			return fmt.Errorf("ошибка чтения %s: %v", item.src, err)
+		}
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("ошибка записи в файл: %v", err)
+	}
+
+	os.Chmod(item.dest, perm)
+	os.Chtimes(item.dest, item.info.ModTime(), item.info.ModTime())
+	return nil
+}
+
+func dirPerm(mode os.FileMode) os.FileMode {
+	if mode.Perm() == 0 {
+		return 0755
+	}
+	return mode.Perm() | 0700
+}
+
+func percent(done, total int64) int {
+	if total <= 0 {
+		return 100
+	}
+	return int(float64(done) / float64(total) * 100)
+}
+
+// expandHome раскрывает ~ в начале пути
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
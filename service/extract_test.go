@@ -0,0 +1,201 @@
+package service
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KharpukhaevV/filemanager/vfs"
+)
+
+func TestSafeJoin(t *testing.T) {
+	target := filepath.Join(string(os.PathSeparator), "tmp", "target")
+	tests := []struct {
+		rel  string
+		want string // пустая строка — путь должен быть отклонён
+	}{
+		{"a.txt", filepath.Join(target, "a.txt")},
+		{"dir/../a.txt", filepath.Join(target, "a.txt")},
+		{".", target},
+		{"../evil.txt", ""},
+		{"dir/../../evil.txt", ""},
+		{"../target-sibling/evil.txt", ""},
+		{"/etc/passwd", filepath.Join(target, "etc", "passwd")},
+	}
+	for _, tt := range tests {
+		dest, err := safeJoin(target, tt.rel)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: путь %s должен быть отклонён", tt.rel, dest)
+			}
+			continue
+		}
+		if err != nil || dest != tt.want {
+			t.Errorf("%q: получено %q, %v; ожидалось %q", tt.rel, dest, err, tt.want)
+		}
+	}
+}
+
+// tarEntry — запись тестового tar-архива; linkname задаёт символическую ссылку
+type tarEntry struct {
+	name, data, linkname string
+}
+
+// tarFS собирает tar-архив в памяти и открывает его как файловую систему
+func tarFS(t *testing.T, entries []tarEntry) vfs.FileSystem {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg}
+		if e.linkname != "" {
+			header = &tar.Header{Name: e.name, Mode: 0777, Linkname: e.linkname, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := vfs.NewTarFS(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	}, vfs.CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+// hostileFS отдаёт записи с именами как есть, без нормализации архивов
+type hostileFS struct {
+	vfs.FileSystem
+	names []string
+}
+
+type hostileInfo struct {
+	os.FileInfo
+	name string
+	dir  bool
+}
+
+func (i hostileInfo) Name() string { return i.name }
+func (i hostileInfo) IsDir() bool  { return i.dir }
+func (i hostileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (h hostileFS) Stat(name string) (os.FileInfo, error) {
+	return hostileInfo{name: filepath.Base(name), dir: name == ""}, nil
+}
+
+func (h hostileFS) ReadDir(name string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	for _, n := range h.names {
+		infos = append(infos, hostileInfo{name: n})
+	}
+	return infos, nil
+}
+
+// assertEmpty проверяет, что в директории ничего не появилось
+func assertEmpty(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("записано за пределами директории назначения: %v", entries)
+	}
+}
+
+func TestExtractHostileArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    func(t *testing.T, outside string) vfs.FileSystem
+		prepare func(t *testing.T, target, outside string) // состояние target до распаковки
+	}{
+		{
+			name: "имена с ../ и абсолютные пути",
+			fsys: func(t *testing.T, outside string) vfs.FileSystem {
+				return tarFS(t, []tarEntry{
+					{name: "../../evil.txt", data: "x"},
+					{name: "/abs/evil.txt", data: "x"},
+					{name: "dir/../../evil2.txt", data: "x"},
+				})
+			},
+		},
+		{
+			name: "имена без нормализации",
+			fsys: func(t *testing.T, outside string) vfs.FileSystem {
+				return hostileFS{names: []string{"../evil.txt", "../../evil.txt"}}
+			},
+		},
+		{
+			name: "ссылка наружу и файл через неё",
+			fsys: func(t *testing.T, outside string) vfs.FileSystem {
+				return tarFS(t, []tarEntry{
+					{name: "link", linkname: outside},
+					{name: "rel", linkname: "../outside"},
+					{name: "link/evil.txt", data: "x"},
+					{name: "rel/evil.txt", data: "x"},
+				})
+			},
+		},
+		{
+			name: "ссылка наружу уже в директории назначения",
+			fsys: func(t *testing.T, outside string) vfs.FileSystem {
+				return tarFS(t, []tarEntry{{name: "link/evil.txt", data: "x"}})
+			},
+			prepare: func(t *testing.T, target, outside string) {
+				if err := os.MkdirAll(target, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(outside, filepath.Join(target, "link")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			target := filepath.Join(root, "target")
+			outside := filepath.Join(root, "outside")
+			if err := os.Mkdir(outside, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.prepare != nil {
+				tt.prepare(t, target, outside)
+			}
+
+			job := &extractJob{fsys: tt.fsys(t, outside), sources: []string{""}, target: target, overwrite: true}
+			// Опасный план может быть отклонён целиком или при распаковке —
+			// важно лишь, что за пределы target ничего не попало
+			if _, err := job.plan(); err == nil {
+				job.run(func(string) {})
+			}
+
+			assertEmpty(t, outside)
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.Name() != "target" && e.Name() != "outside" {
+					t.Fatalf("записано за пределами директории назначения: %s", e.Name())
+				}
+			}
+		})
+	}
+}
@@ -1,15 +1,17 @@
 package service
 
 import (
-	"archive/zip"
 	"fmt"
 	"github.com/KharpukhaevV/filemanager/icons"
 	"github.com/KharpukhaevV/filemanager/models"
 	"github.com/KharpukhaevV/filemanager/utils"
-	"io"
+	"github.com/KharpukhaevV/filemanager/vfs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,66 +22,146 @@ import (
 
 // FileManagerState хранит состояние файлового менеджера
 type FileManagerState struct {
-	Cwd               string
-	files             []os.FileInfo
-	cursor            int
-	offset            int
-	preview           bool
-	previewView       viewport.Model
-	width             int
-	height            int
-	visibleItems      int
-	previewFile       string
-	cursorPositions   map[string]int
-	mode              string
-	input             string
-	confirmDelete     bool
-	ExitWithDir       bool
-	searchMode        bool
-	searchQuery       string
-	searchPosition    int
-	searchMatches     []int
-	currentMatch      int
-	previewContent    string
-	SftpClient        *sftp.Client
-	SftpSession       *ssh.Session
-	isRemote          bool
-	remoteHost        string
-	remoteUser        string
-	remotePassword    string
-	inArchive         bool
-	archivePath       string
-	ArchiveFiles      []*zip.File
-	ArchiveReader     *zip.ReadCloser
-	RemoteArchiveFile io.ReadCloser
-	prevCursorPos     int
-	status            string
+	Cwd             string
+	files           []os.FileInfo
+	cursor          int
+	offset          int
+	preview         bool
+	previewView     viewport.Model
+	width           int
+	height          int
+	visibleItems    int
+	previewFile     string
+	cursorPositions map[string]int
+	mode            string
+	input           string
+	confirmDelete   bool
+	ExitWithDir     bool
+	searchMode      bool
+	searchQuery     string
+	searchPosition  int
+	searchMatches   []int
+	currentMatch    int
+	previewContent  string
+	fs              vfs.FileSystem
+	SftpClient      *sftp.Client
+	SftpSession     *ssh.Session
+	sshClients      []*ssh.Client // цепочка SSH: переходы и целевой сервер
+	isRemote        bool
+	remoteHost      string
+	remoteUser      string
+	remotePassword  string
+	keyPassphrases  map[string]string // парольные фразы ключей SSH на время подключения
+	pendingKey      string            // ключ, для которого запрошена парольная фраза
+	pendingHostKey  *errUnknownHost   // ключ сервера, ожидающий подтверждения
+	sshDial         *sshDial          // SSH-подключение, устанавливаемое в фоне
+	connectTarget   *remoteTarget     // подключение FTP, WebDAV или S3, устанавливаемое в фоне
+	sshPrompt       *sshPromptMsg     // вопросы сервера keyboard-interactive
+	sshAnswers      []string          // ответы на уже заданные вопросы
+	profiles        []models.SFTPProfile
+	profileCursor   int                 // 0 — пункт «Новое подключение»
+	profileFilter   string              // фильтр списка на время формы и подтверждения
+	profile         *models.SFTPProfile // профиль активного подключения
+	profileForm     *profileForm
+	vault           *credentialVault // открытое хранилище паролей
+	pendingVault    *vaultRequest    // действие, ожидающее мастер-пароля
+	vaultPassphrase string           // мастер-пароль нового хранилища до подтверждения
+	archives        []*archiveLayer
+	marked          map[string]bool
+	pendingExtract  *extractJob
+	pendingUpload   *transferJob
+	queue           []*transferJob // очередь скачиваний и загрузок
+	active          *transfer      // выполняющаяся задача очереди
+	queueCursor     int
+	status          string
 }
 
 // ===================== Работа с файлами и директориями =====================
 
-func readFiles(dir string, m *FileManagerState) []os.FileInfo {
-	if m != nil && m.inArchive {
-		var files []os.FileInfo
-		for _, f := range m.ArchiveFiles {
-			files = append(files, f.FileInfo())
-		}
-		return files
+func readFiles(fsys vfs.FileSystem, dir string) []os.FileInfo {
+	files, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil
 	}
 
-	if m != nil && m.isRemote {
-		dir = filepath.ToSlash(dir)
-		return m.readRemoteFiles(dir)
+	utils.SortFiles(files)
+	return files
+}
+
+// activeFS возвращает файловую систему, которую сейчас просматривает пользователь
+func (m *FileManagerState) activeFS() vfs.FileSystem {
+	if layer := m.topArchive(); layer != nil {
+		return layer.fs
 	}
+	return m.fs
+}
 
-	files, _ := os.ReadDir(dir)
-	infos := make([]os.FileInfo, len(files))
-	for i, f := range files {
-		infos[i], _ = f.Info()
+// currentDir возвращает путь текущей директории внутри activeFS
+func (m *FileManagerState) currentDir() string {
+	if layer := m.topArchive(); layer != nil {
+		return path.Join(".", layer.dir)
 	}
+	return m.Cwd
+}
 
-	utils.SortFiles(infos)
-	return infos
+// entryPath возвращает путь к элементу текущей директории внутри activeFS
+func (m *FileManagerState) entryPath(name string) string {
+	if layer := m.topArchive(); layer != nil {
+		return path.Join(layer.dir, name)
+	}
+	return filepath.Join(m.Cwd, name)
+}
+
+// displayPath возвращает путь текущей директории для верхней строки и истории курсора
+func (m *FileManagerState) displayPath() string {
+	if m.inArchive() {
+		return m.archiveDisplayPath()
+	}
+	return m.Cwd
+}
+
+func (m *FileManagerState) refreshFiles() {
+	m.files = readFiles(m.activeFS(), m.currentDir())
+}
+
+// toggleMark отмечает текущий элемент для групповых операций и переводит курсор ниже
+func (m *FileManagerState) toggleMark() {
+	key := m.entryPath(m.files[m.cursor].Name())
+	if m.marked[key] {
+		delete(m.marked, key)
+	} else {
+		m.marked[key] = true
+	}
+	if m.cursor < len(m.files)-1 {
+		m.cursor++
+		if m.cursor >= m.offset+m.visibleItems {
+			m.offset = min(m.cursor-m.visibleItems+1, len(m.files)-m.visibleItems)
+		}
+	}
+}
+
+// markedPaths возвращает отмеченные элементы в порядке сортировки путей
+func (m *FileManagerState) markedPaths() []string {
+	paths := make([]string, 0, len(m.marked))
+	for p := range m.marked {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Close освобождает открытые архивы и удалённые подключения
+func (m *FileManagerState) Close() {
+	m.saveTransfers()
+	m.closeArchives()
+	m.saveProfileDir()
+	if m.isRemote {
+		m.fs.Close()
+	}
+	if m.SftpSession != nil {
+		m.SftpSession.Close()
+	}
+	closeSSHClients(m.sshClients)
 }
 
 // ===================== Отображение интерфейса =====================
@@ -92,16 +174,31 @@ func (m *FileManagerState) View() string {
 	var topLine string
 	if m.mode != "normal" {
 		prompt := m.getPrompt()
-		input := fmt.Sprintf("%s%s", prompt, m.input)
+		input := fmt.Sprintf("%s%s", prompt, m.maskedInput())
 		topLine = models.Stls.TopLineInput.Render(input)
 	} else {
-		topLine = models.Stls.TopLine.Render(m.Cwd)
+		topLine = models.Stls.TopLine.Render(m.displayPath())
 	}
 
-	leftContent := m.renderNavigation(leftWidth)
+	var leftContent string
+	if m.inProfiles() {
+		leftContent = m.renderProfiles()
+	} else if m.inQueue() {
+		leftContent = m.renderQueue()
+	} else {
+		leftContent = m.renderNavigation(leftWidth)
+	}
 
 	var rightContent string
-	if m.preview {
+	if m.inProfiles() {
+		rightContent = lipgloss.NewStyle().
+			Height(panelHeight).
+			Render(m.renderProfileDetails())
+	} else if m.inQueue() {
+		rightContent = lipgloss.NewStyle().
+			Height(panelHeight).
+			Render(m.renderJobDetails())
+	} else if m.preview {
 		rightContent = m.renderPreview(rightWidth, panelHeight)
 	} else {
 		rightContent = lipgloss.NewStyle().
@@ -122,16 +219,22 @@ func (m *FileManagerState) View() string {
 
 	mainContent := lipgloss.JoinHorizontal(lipgloss.Top, leftBox, rightBox)
 
-	status := models.Stls.Header.Width(m.width).
-		Render(fmt.Sprintf("↑/↓: навигация | Enter: открыть | Пробел: превью | b: назад | q: выход | SFTP: %s (%s)", m.remoteHost, func() string {
-			if m.isRemote {
-				if m.status != "" {
-					return m.status
-				}
-				return "подключен"
-			}
-			return "отключен"
-		}()))
+	statusText := fmt.Sprintf("↑/↓: навигация | Enter: открыть | Пробел: превью | b: назад | q: выход | Сервер: %s (%s)", m.remoteHost, func() string {
+		if m.isRemote {
+			return "подключен"
+		}
+		return "отключен"
+	}())
+	if m.inProfiles() {
+		statusText = "↑/↓: выбор | Enter: подключиться | Ctrl+a: добавить | Ctrl+e: изменить | Ctrl+d: удалить | Esc: закрыть"
+	}
+	if m.inQueue() {
+		statusText = "↑/↓: выбор | Пробел: пауза/продолжить | r: повторить | K/J: переместить | d: удалить | c: убрать готовые | Esc: закрыть"
+	}
+	if m.status != "" {
+		statusText += " | " + m.status
+	}
+	status := models.Stls.Header.Width(m.width).Render(statusText)
 
 	fullUI := lipgloss.JoinVertical(lipgloss.Left,
 		topLine,
@@ -163,6 +266,9 @@ func (m *FileManagerState) renderNavigation(width int) string {
 	for i := start; i < end; i++ {
 		f := m.files[i]
 		icon := icons.GetIcon(f.Name(), f.IsDir())
+		if m.marked[m.entryPath(f.Name())] {
+			icon = "*" + icon
+		}
 		name := utils.TruncateFileName(icon+" "+f.Name(), maxNameLength)
 		lastWrite := f.ModTime().Format("2006-01-02 15:04:05")
 		size := utils.FormatSize(f.Size())
@@ -190,32 +296,97 @@ func (m *FileManagerState) getPrompt() string {
 		return "Переместить (относительный путь):"
 	case "delete":
 		return "Удалить? (y/n):"
-	case "sftp_confirm":
-		return "Использовать последнее сохраненное подключение? (y/n):"
+	case "compress":
+		return "Создать архив (.zip или .tar.gz):"
+	case "extract":
+		return "Распаковать в:"
+	case "extract_overwrite":
+		return "Перезаписать существующие файлы? (y/n):"
+	case "upload":
+		return fmt.Sprintf("Загрузить в %s (локальный путь, Tab — дополнить):", m.Cwd)
+	case "upload_overwrite":
+		return "Перезаписать файлы на сервере? (y/n):"
+	case "profiles":
+		return "Профили (фильтр):"
+	case "transfers":
+		return "Очередь передач"
+	case "profile_form":
+		return m.profileFormPrompt()
+	case "profile_delete":
+		return fmt.Sprintf("Удалить профиль %s? (y/n):", m.profiles[m.selectedProfile()].Name)
 	case "sftp_host":
-		return "Введите хост:"
+		return "Введите хост (host:port, алиас ssh — Tab, ftp://, ftps://, dav://, davs://, s3://):"
 	case "sftp_user":
+		if strings.HasPrefix(m.remoteHost, "s3") {
+			return "Введите access key:"
+		}
 		return "Введите логин:"
 	case "sftp_password":
+		if strings.HasPrefix(m.remoteHost, "s3") {
+			return "Введите secret key:"
+		}
+		if target, err := parseRemoteTarget(m.remoteHost); err == nil && target.scheme == "sftp" {
+			return "Введите пароль (Enter — вход по ключу или через ssh-agent):"
+		}
 		return "Введите пароль:"
+	case "host_key_confirm":
+		key := m.pendingHostKey.key
+		return fmt.Sprintf("Сервер %s не найден в known_hosts. Ключ %s %s. Доверять? (y/n):",
+			m.pendingHostKey.host, key.Type(), ssh.FingerprintSHA256(key))
+	case "ssh_passphrase":
+		return fmt.Sprintf("Парольная фраза для ключа %s (Enter — пропустить):", m.pendingKey)
+	case "connecting":
+		return fmt.Sprintf("Подключение к %s... (Esc — отменить)", m.remoteHost)
+	case "ssh_prompt":
+		return m.sshPromptText()
+	case "vault_unlock":
+		return "Мастер-пароль хранилища паролей (Enter — пропустить):"
+	case "vault_create":
+		return "Придумайте мастер-пароль для хранилища паролей (Enter — не сохранять):"
+	case "vault_confirm":
+		return "Повторите мастер-пароль:"
+	case "vault_optout":
+		return "Больше не предлагать сохранять пароли? (y/n):"
 	default:
 		return ""
 	}
 }
 
+// maskedInput возвращает ввод для верхней строки; пароли и парольные фразы
+// заменяются звёздочками
+func (m *FileManagerState) maskedInput() string {
+	switch m.mode {
+	case "sftp_password", "ssh_passphrase", "vault_unlock", "vault_create", "vault_confirm":
+	case "profile_form":
+		if !profileFields[m.profileForm.field].secret {
+			return m.input
+		}
+	case "ssh_prompt":
+		if m.sshPromptEcho() {
+			return m.input
+		}
+	default:
+		return m.input
+	}
+	return strings.Repeat("*", utf8.RuneCountInString(m.input))
+}
+
 func InitialModel() tea.Model {
 	cwd, _ := os.Getwd()
-	files := readFiles(cwd, nil)
+	fsys := vfs.NewLocalFS()
+	files := readFiles(fsys, cwd)
 
 	m := &FileManagerState{
 		Cwd:             cwd,
+		fs:              fsys,
 		files:           files,
 		cursor:          0,
 		preview:         false,
 		mode:            "normal",
 		cursorPositions: make(map[string]int),
+		marked:          make(map[string]bool),
 		previewView:     viewport.New(0, 0),
-		prevCursorPos:   -1,
+		queue:           loadTransfers(),
 	}
 
 	if parent := filepath.Dir(cwd); parent != cwd {
@@ -226,15 +397,8 @@ func InitialModel() tea.Model {
 }
 
 func getPositionInParent(current, parent string, m *FileManagerState) int {
-	var files []os.FileInfo
-	var base string
-	if m.isRemote {
-		files = m.readRemoteFiles(filepath.ToSlash(parent))
-		base = filepath.ToSlash(filepath.Base(current))
-	} else {
-		files = readFiles(parent, nil)
-		base = filepath.Base(current)
-	}
+	files := readFiles(m.fs, parent)
+	base := filepath.Base(current)
 
 	for i, f := range files {
 		if f.Name() == base {
@@ -258,6 +422,37 @@ func (m *FileManagerState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.previewView.Height = m.height - 4
 		return m, nil
 
+	case taskProgressMsg:
+		m.status = msg.status
+		return m, msg.task.wait()
+
+	case transferProgressMsg:
+		return m.updateTransfer(msg)
+
+	case transferSourceMsg:
+		return m.updateTransferSource(msg)
+
+	case transferDoneMsg:
+		return m.finishTransfer(msg)
+
+	case sshPromptMsg:
+		return m.showSSHPrompt(msg)
+
+	case sshDialDoneMsg:
+		return m.finishSFTP(msg)
+
+	case remoteDialDoneMsg:
+		return m.finishRemote(msg)
+
+	case taskDoneMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Ошибка: %v", msg.err)
+		} else {
+			m.status = msg.status
+		}
+		m.refreshFiles()
+		return m, nil
+
 	case tea.KeyMsg:
 		if m.preview {
 			if m.searchMode {
@@ -327,13 +522,49 @@ func (m *FileManagerState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, cmd
 			}
 		} else {
+			if m.mode == "profiles" {
+				return m.updateProfilePicker(msg)
+			}
+			if m.mode == "transfers" {
+				return m.updateQueue(msg)
+			}
+			if m.mode == "connecting" {
+				switch msg.String() {
+				case "esc":
+					return m, m.cancelConnect()
+				case "ctrl+c":
+					return m, tea.Quit
+				}
+				return m, nil
+			}
 			if m.mode != "normal" {
 				switch msg.String() {
 				case "esc":
+					// Из формы и подтверждения удаления возвращаемся к списку профилей
+					if m.mode == "profile_form" || m.mode == "profile_delete" {
+						m.openProfiles()
+						return m, nil
+					}
+					if m.mode == "ssh_prompt" {
+						m.mode = "normal"
+						return m, m.cancelSSHDial()
+					}
+					m.cancelExtract()
+					m.cancelUpload()
+					m.pendingVault = nil
+					m.vaultPassphrase = ""
 					m.mode = "normal"
+					m.input = ""
 					return m, nil
 				case "enter":
 					return m.handleInput()
+				case "tab":
+					switch m.mode {
+					case "sftp_host":
+						m.completeHost()
+					case "upload":
+						m.completeLocalPath()
+					}
 				case "backspace":
 					if len(m.input) > 0 {
 						m.input = m.input[:len(m.input)-1]
@@ -372,6 +603,16 @@ func (m *FileManagerState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.input = ""
 				m.confirmDelete = false
 				return m, nil
+			case "v":
+				if len(m.files) > 0 {
+					m.toggleMark()
+				}
+			case "e":
+				return m.startExtract(false)
+			case "E":
+				return m.startExtract(true)
+			case "z":
+				return m.startCompress()
 			case "ctrl+c", "q":
 				return m, tea.Quit
 			case "ctrl+k":
@@ -426,25 +667,30 @@ func (m *FileManagerState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case "ctrl+s":
 				if m.isRemote {
-					m.disconnectSFTP()
-					return m, tea.Println("Отключено от SFTP")
+					m.disconnectRemote()
+					return m, tea.Println("Отключено от сервера")
 				} else {
-					config, err := loadSFTPConfig()
-					if err == nil && config.Host != "" && config.User != "" && config.Password != "" {
-						m.mode = "sftp_confirm"
-						m.input = ""
+					m.keyPassphrases = make(map[string]string)
+					return m.migratePlaintextSecrets(func() (tea.Model, tea.Cmd) {
+						m.openProfiles()
 						return m, nil
-					}
-
-					m.mode = "sftp_host"
-					m.input = ""
-					return m, nil
+					})
 				}
 			case "ctrl+x":
 				if !m.isRemote {
-					return m, tea.Println("SFTP не подключен")
+					return m, tea.Println("Нет подключения к серверу")
 				}
 				return m, m.downloadFile()
+			case "u":
+				return m.startUpload()
+			case "t":
+				m.openQueue()
+			case "X":
+				if m.active == nil {
+					m.status = "Нет активных передач"
+					return m, nil
+				}
+				m.stopTransfer("cancel")
 			}
 		}
 	}
@@ -2,9 +2,6 @@ package service
 
 import (
 	"fmt"
-	"github.com/KharpukhaevV/filemanager/models"
-	"os"
-	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -15,87 +12,119 @@ import (
 func (m *FileManagerState) handleInput() (tea.Model, tea.Cmd) {
 	switch m.mode {
 	case "create":
+		if err := m.checkArchiveBusy(); err != nil {
+			m.status = err.Error()
+			break
+		}
+		path := m.entryPath(m.input)
 		if strings.HasSuffix(m.input, "/") {
-			if m.isRemote {
-				m.SftpClient.Mkdir(filepath.ToSlash(filepath.Join(m.Cwd, m.input)))
-			} else {
-				os.Mkdir(filepath.Join(m.Cwd, m.input), 0755)
+			if err := m.activeFS().Mkdir(path); err != nil {
+				m.status = fmt.Sprintf("Ошибка создания: %v", err)
 			}
 		} else {
-			if m.isRemote {
-				m.SftpClient.Create(filepath.ToSlash(filepath.Join(m.Cwd, m.input)))
-			} else {
-				os.Create(filepath.Join(m.Cwd, m.input))
+			f, err := m.activeFS().Create(path)
+			if err == nil {
+				err = f.Close()
+			}
+			if err != nil {
+				m.status = fmt.Sprintf("Ошибка создания: %v", err)
 			}
 		}
 	case "rename":
-		if m.isRemote {
-			m.SftpClient.Rename(
-				filepath.ToSlash(filepath.Join(m.Cwd, m.files[m.cursor].Name())),
-				filepath.ToSlash(filepath.Join(m.Cwd, m.input)),
-			)
-		} else {
-			os.Rename(
-				filepath.Join(m.Cwd, m.files[m.cursor].Name()),
-				filepath.Join(m.Cwd, m.input),
-			)
+		if err := m.checkArchiveBusy(); err != nil {
+			m.status = err.Error()
+			break
+		}
+		if err := m.activeFS().Rename(
+			m.entryPath(m.files[m.cursor].Name()),
+			m.entryPath(m.input),
+		); err != nil {
+			m.status = fmt.Sprintf("Ошибка переименования: %v", err)
 		}
 	case "move":
-		newPath := filepath.Join(m.Cwd, m.input)
-		if m.isRemote {
-			if err := m.SftpClient.Rename(
-				filepath.ToSlash(filepath.Join(m.Cwd, m.files[m.cursor].Name())),
-				filepath.ToSlash(newPath),
-			); err != nil {
-				fmt.Println("Ошибка перемещения:", err)
-			}
-		} else {
-			if err := os.Rename(
-				filepath.Join(m.Cwd, m.files[m.cursor].Name()),
-				newPath,
-			); err != nil {
-				fmt.Println("Ошибка перемещения:", err)
-			}
+		if err := m.checkArchiveBusy(); err != nil {
+			m.status = err.Error()
+			break
+		}
+		if err := m.activeFS().Rename(
+			m.entryPath(m.files[m.cursor].Name()),
+			m.entryPath(m.input),
+		); err != nil {
+			m.status = fmt.Sprintf("Ошибка перемещения: %v", err)
 		}
 	case "delete":
 		if m.input == "y" {
-			if m.isRemote {
-				if err := m.SftpClient.Remove(filepath.ToSlash(filepath.Join(m.Cwd, m.files[m.cursor].Name()))); err != nil {
-					fmt.Println("Ошибка удаления:", err)
-				}
-			} else {
-				if err := os.RemoveAll(filepath.Join(m.Cwd, m.files[m.cursor].Name())); err != nil {
-					fmt.Println("Ошибка удаления:", err)
-				}
+			if err := m.checkArchiveBusy(); err != nil {
+				m.status = err.Error()
+				break
+			}
+			if err := m.activeFS().Remove(m.entryPath(m.files[m.cursor].Name())); err != nil {
+				m.status = fmt.Sprintf("Ошибка удаления: %v", err)
 			}
 			newPos := max(m.cursor-1, 0)
 			m.cursor = newPos
 		}
 
-	case "sftp_confirm":
-		if m.input == "y" {
-			// Если пользователь подтвердил использование сохраненных данных
-			config, _ := loadSFTPConfig()
-			m.remoteHost = config.Host
-			m.remoteUser = config.User
-			m.remotePassword = config.Password
-			if err := m.initSFTP(); err != nil {
-				return m, tea.Println("Ошибка подключения:", err)
-			}
-			m.mode = "normal"
+	case "compress":
+		if m.input == "" {
 			return m, nil
-		} else if m.input == "n" {
-			// Если пользователь выбрал ввод новых данных
-			m.mode = "sftp_host"
-			m.input = ""
+		}
+		return m.runCompress(m.input)
+
+	case "extract":
+		if m.input == "" {
 			return m, nil
 		}
-		return m, nil
+		return m.planExtract(m.input)
+
+	case "extract_overwrite":
+		if m.input != "y" && m.input != "n" {
+			return m, nil
+		}
+		m.pendingExtract.overwrite = m.input == "y"
+		return m.runExtract()
+
+	case "upload":
+		if strings.TrimSpace(m.input) == "" {
+			return m, nil
+		}
+		return m.planUpload(m.input)
+
+	case "upload_overwrite":
+		if m.input != "y" && m.input != "n" {
+			return m, nil
+		}
+		m.pendingUpload.Overwrite = m.input == "y"
+		return m.runUpload()
 
 	case "sftp_host":
 		m.remoteHost = m.input
-		m.mode = "sftp_user"
 		m.input = ""
+		// Ключи сохранённого профиля S3 не запрашиваются; secret key берётся
+		// из хранилища, а если его там нет — запрашивается
+		if profile, ok := savedS3Profile(m.remoteHost); ok {
+			m.remoteUser = profile.AccessKey
+			return m.withSecret(s3Secret(profile.Name), func(secret string) (tea.Model, tea.Cmd) {
+				if secret == "" && profile.SecretKey == "" {
+					m.mode = "sftp_password"
+					return m, nil
+				}
+				m.remotePassword = secret
+				return m.startConnect()
+			})
+		}
+		// Логин алиаса из ~/.ssh/config не запрашивается, а при заданном
+		// IdentityFile не запрашивается и пароль
+		if hostConfig := sshHostConfigFor(m.remoteHost); hostConfig != nil && hostConfig.User != "" {
+			m.remoteUser = hostConfig.User
+			if len(hostConfig.IdentityFiles) == 0 {
+				m.mode = "sftp_password"
+				return m, nil
+			}
+			m.remotePassword = ""
+			return m.startConnect()
+		}
+		m.mode = "sftp_user"
 		return m, nil
 
 	case "sftp_user":
@@ -106,27 +135,60 @@ func (m *FileManagerState) handleInput() (tea.Model, tea.Cmd) {
 
 	case "sftp_password":
 		m.remotePassword = m.input
+		return m.startConnect()
 
-		config := &models.SFTPConfig{
-			Host:     m.remoteHost,
-			User:     m.remoteUser,
-			Password: m.remotePassword,
+	case "profile_form":
+		return m.submitProfileField()
+
+	case "profile_delete":
+		if m.input != "y" && m.input != "n" {
+			return m, nil
+		}
+		deleted := ""
+		if m.input == "y" {
+			deleted = m.deleteProfile()
 		}
-		if err := saveSFTPConfig(config); err != nil {
-			return m, tea.Println("Не удалось сохранить конфигурацию:", err)
+		m.mode = "profiles"
+		m.input = m.profileFilter
+		if deleted != "" && vaultHasSecret(profileSecret(deleted)) {
+			return m.changeSecrets(func(secrets map[string]string) {
+				delete(secrets, profileSecret(deleted))
+			})
 		}
+		return m, nil
+
+	case "vault_unlock", "vault_create", "vault_confirm", "vault_optout":
+		return m.handleVaultInput()
 
-		if err := m.initSFTP(); err != nil {
+	case "host_key_confirm":
+		if m.input != "y" && m.input != "n" {
+			return m, nil
+		}
+		pending := m.pendingHostKey
+		m.pendingHostKey = nil
+		if m.input == "n" {
 			m.mode = "normal"
 			m.input = ""
-			return m, tea.Println("Ошибка подключения:", err)
+			return m, tea.Println("Подключение отменено: ключ сервера не принят")
 		}
-		m.mode = "normal"
-		m.input = ""
-		return m, nil
+		if err := trustHostKey(pending.host, pending.key); err != nil {
+			m.mode = "normal"
+			m.input = ""
+			return m, tea.Println("Не удалось сохранить ключ в known_hosts:", err)
+		}
+		return m.startConnect()
+
+	case "ssh_prompt":
+		return m.answerSSHPrompt()
+
+	case "ssh_passphrase":
+		// Пустой ввод пропускает ключ
+		m.keyPassphrases[m.pendingKey] = m.input
+		m.pendingKey = ""
+		return m.startConnect()
 	}
 
 	m.mode = "normal"
-	m.files = readFiles(m.Cwd, m)
+	m.refreshFiles()
 	return m, nil
 }
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ===================== Проверка ключей серверов =====================
+
+// Общий список известных хостов, который читается, но не изменяется
+const systemKnownHosts = "/etc/ssh/ssh_known_hosts"
+
+// errUnknownHost сообщает, что ключа сервера нет в known_hosts; подключение
+// повторяется после того, как пользователь подтвердит отпечаток
+type errUnknownHost struct {
+	host string // адрес в том виде, в каком он передан в ssh.Dial
+	key  ssh.PublicKey
+}
+
+func (e *errUnknownHost) Error() string {
+	return fmt.Sprintf("ключ сервера %s не найден в known_hosts", e.host)
+}
+
+// errHostKeyMismatch — ключ сервера отличается от сохранённого.
+// Подключение в этом случае не выполняется.
+type errHostKeyMismatch struct {
+	host  string
+	key   ssh.PublicKey
+	known knownhosts.KnownKey
+}
+
+func (e *errHostKeyMismatch) Error() string {
+	return fmt.Sprintf("ВНИМАНИЕ: КЛЮЧ СЕРВЕРА %s ИЗМЕНИЛСЯ! Возможна атака посредника (MITM). "+
+		"Получен %s ключ %s, в %s:%d сохранён %s. Подключение отклонено; "+
+		"если смена ключа ожидаема, удалите старую запись из known_hosts",
+		e.host, e.key.Type(), ssh.FingerprintSHA256(e.key),
+		e.known.Filename, e.known.Line, ssh.FingerprintSHA256(e.known.Key))
+}
+
+// userKnownHosts возвращает путь к ~/.ssh/known_hosts
+func userKnownHosts() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".ssh", "known_hosts"), nil
+}
+
+// knownHostsDB читает существующие файлы known_hosts; хешированные имена
+// хостов (|1|...) поддерживаются пакетом knownhosts
+func knownHostsDB() (ssh.HostKeyCallback, error) {
+	var files []string
+	if path, err := userKnownHosts(); err == nil {
+		files = append(files, path)
+	}
+	files = append(files, systemKnownHosts)
+
+	var existing []string
+	for _, path := range files {
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+	callback, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать known_hosts: %v", err)
+	}
+	return callback, nil
+}
+
+// hostKeyCallback проверяет ключ сервера по known_hosts. Неизвестный ключ
+// возвращается как errUnknownHost для подтверждения пользователем, изменённый —
+// как errHostKeyMismatch.
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	check, err := knownHostsDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return &errUnknownHost{host: hostname, key: key}
+			}
+			return &errHostKeyMismatch{host: hostname, key: key, known: keyErr.Want[0]}
+		}
+		var revoked *knownhosts.RevokedError
+		if errors.As(err, &revoked) {
+			return fmt.Errorf("ключ сервера %s отозван (%s:%d), подключение отклонено",
+				hostname, revoked.Revoked.Filename, revoked.Revoked.Line)
+		}
+		return err
+	}, nil
+}
+
+// knownHostKeyAlgorithms возвращает алгоритмы ключей, уже сохранённых для
+// хоста, чтобы сервер с несколькими ключами предъявил известный, а не
+// вызвал ложное предупреждение о смене ключа
+func knownHostKeyAlgorithms(addr string) []string {
+	check, err := knownHostsDB()
+	if err != nil {
+		return nil
+	}
+
+	// Заведомо неизвестный ключ: в ответ придёт список сохранённых
+	probe, err := ssh.NewPublicKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public())
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(addr, &net.TCPAddr{IP: net.IPv4zero}, probe), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, known := range keyErr.Want {
+		keyType := known.Key.Type()
+		if seen[keyType] {
+			continue
+		}
+		seen[keyType] = true
+		if keyType == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, keyType)
+	}
+	return algorithms
+}
+
+// trustHostKey дописывает принятый ключ в ~/.ssh/known_hosts. Если в файле
+// уже есть хешированные записи, новая запись тоже хешируется.
+func trustHostKey(host string, key ssh.PublicKey) error {
+	path, err := userKnownHosts()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	entry := knownhosts.Normalize(host)
+	if knownHostsHashed(path) {
+		entry = knownhosts.HashHostname(entry)
+	}
+	line := knownhosts.Line([]string{entry}, key)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// knownHostsHashed проверяет, хранит ли файл имена хостов в хешированном виде
+func knownHostsHashed(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return strings.HasPrefix(line, "|1|")
+	}
+	return false
+}
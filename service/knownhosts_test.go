@@ -0,0 +1,156 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testHomeKnownHosts подменяет домашнюю директорию и возвращает путь к known_hosts в ней
+func testHomeKnownHosts(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+func ed25519Key(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func ecdsaKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// checkHostKey проверяет ключ так же, как это делает ssh.Dial для host:22
+func checkHostKey(t *testing.T, key ssh.PublicKey) error {
+	t.Helper()
+	callback, err := hostKeyCallback()
+	if err != nil {
+		t.Fatalf("hostKeyCallback: %v", err)
+	}
+	return callback("example.com:22", &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}, key)
+}
+
+func TestHostKeyCallback(t *testing.T) {
+	path := testHomeKnownHosts(t)
+	key := ed25519Key(t)
+
+	// Неизвестный ключ уходит на подтверждение пользователю
+	var unknown *errUnknownHost
+	if err := checkHostKey(t, key); !errors.As(err, &unknown) {
+		t.Fatalf("ожидалась errUnknownHost, получено %v", err)
+	}
+	if unknown.host != "example.com:22" || !slices.Equal(unknown.key.Marshal(), key.Marshal()) {
+		t.Fatalf("неверные данные неизвестного хоста: %s", unknown.host)
+	}
+
+	if err := trustHostKey(unknown.host, unknown.key); err != nil {
+		t.Fatalf("trustHostKey: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(data), "example.com ") {
+		t.Fatalf("запись known_hosts: %q, %v", data, err)
+	}
+	if err := checkHostKey(t, key); err != nil {
+		t.Fatalf("сохранённый ключ не принят: %v", err)
+	}
+
+	// Другой ключ того же типа и ключ другого типа отклоняются как смена ключа
+	for name, changed := range map[string]ssh.PublicKey{"тот же тип": ed25519Key(t), "другой тип": ecdsaKey(t)} {
+		t.Run(name, func(t *testing.T) {
+			var mismatch *errHostKeyMismatch
+			if err := checkHostKey(t, changed); !errors.As(err, &mismatch) {
+				t.Fatalf("ожидалась errHostKeyMismatch, получено %v", err)
+			}
+			if !slices.Equal(mismatch.known.Key.Marshal(), key.Marshal()) || mismatch.known.Filename != path || mismatch.known.Line != 1 {
+				t.Fatalf("неверная сохранённая запись: %s:%d", mismatch.known.Filename, mismatch.known.Line)
+			}
+			if !strings.Contains(mismatch.Error(), ssh.FingerprintSHA256(changed)) {
+				t.Fatalf("в сообщении нет отпечатка нового ключа: %s", mismatch.Error())
+			}
+		})
+	}
+}
+
+func TestKnownHostKeyAlgorithms(t *testing.T) {
+	testHomeKnownHosts(t)
+	if algorithms := knownHostKeyAlgorithms("example.com:22"); algorithms != nil {
+		t.Fatalf("для неизвестного хоста получено %v", algorithms)
+	}
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := ssh.NewPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []ssh.PublicKey{ecdsaKey(t), rsaKey, ecdsaKey(t)} {
+		if err := trustHostKey("example.com:22", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{ssh.KeyAlgoECDSA256, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	if got := knownHostKeyAlgorithms("example.com:22"); !slices.Equal(got, want) {
+		t.Fatalf("алгоритмы: %v, ожидалось %v", got, want)
+	}
+}
+
+func TestTrustHostKeyHashed(t *testing.T) {
+	path := testHomeKnownHosts(t)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	existing := knownhosts.Line([]string{knownhosts.HashHostname("other.example.com")}, ed25519Key(t))
+	if err := os.WriteFile(path, []byte("# комментарий\n"+existing+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	key := ed25519Key(t)
+	if err := trustHostKey("example.com:22", key); err != nil {
+		t.Fatalf("trustHostKey: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if last := lines[len(lines)-1]; !strings.HasPrefix(last, "|1|") || strings.Contains(last, "example.com") {
+		t.Fatalf("новая запись не хеширована: %s", last)
+	}
+	if err := checkHostKey(t, key); err != nil {
+		t.Fatalf("хешированная запись не принята: %v", err)
+	}
+}
@@ -1,8 +1,8 @@
 package service
 
 import (
-	"archive/zip"
-	"github.com/KharpukhaevV/filemanager/utils"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"path"
 	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -10,29 +10,50 @@ import (
 
 // ===================== Навигация и управление =====================
 
+// restoreCursor возвращает курсор на позицию, запомненную для директории key
+func (m *FileManagerState) restoreCursor(key string) {
+	if pos, exists := m.cursorPositions[key]; exists && pos < len(m.files) {
+		m.cursor = pos
+		m.offset = min(m.cursor-m.visibleItems+1, len(m.files)-m.visibleItems)
+	} else {
+		m.cursor = 0
+		m.offset = 0
+	}
+}
+
 func (m *FileManagerState) navigateBack() {
-	if m.inArchive {
-		if m.isRemote {
-			if m.RemoteArchiveFile != nil {
-				m.RemoteArchiveFile.Close()
-				m.RemoteArchiveFile = nil
-			}
-		} else {
-			if m.ArchiveReader != nil {
-				m.ArchiveReader.Close()
-				m.ArchiveReader = nil
+	if layer := m.topArchive(); layer != nil && layer.dir != "" {
+		m.cursorPositions[m.displayPath()] = m.cursor
+		baseName := path.Base(layer.dir)
+		layer.dir = path.Dir(layer.dir)
+		if layer.dir == "." {
+			layer.dir = ""
+		}
+		m.refreshFiles()
+
+		if _, exists := m.cursorPositions[m.displayPath()]; !exists {
+			for i, f := range m.files {
+				if f.Name() == baseName {
+					m.cursorPositions[m.displayPath()] = i
+					break
+				}
 			}
 		}
-		m.inArchive = false
-		m.archivePath = ""
-		m.ArchiveFiles = nil
-		m.files = readFiles(m.Cwd, m)
+		m.restoreCursor(m.displayPath())
+		m.preview = false
+		return
+	}
+
+	if m.inArchive() {
+		m.cursorPositions[m.displayPath()] = m.cursor
+		layer := m.popArchive()
+		baseName := path.Base(filepath.ToSlash(layer.path))
+		m.refreshFiles()
 
-		if m.prevCursorPos >= 0 && m.prevCursorPos < len(m.files) {
-			m.cursor = m.prevCursorPos
+		if layer.prevCursor >= 0 && layer.prevCursor < len(m.files) {
+			m.cursor = layer.prevCursor
 			m.offset = min(m.cursor-m.visibleItems+1, len(m.files)-m.visibleItems)
 		} else {
-			baseName := filepath.Base(m.archivePath)
 			for i, f := range m.files {
 				if f.Name() == baseName {
 					m.cursor = i
@@ -40,6 +61,7 @@ func (m *FileManagerState) navigateBack() {
 				}
 			}
 		}
+		m.preview = false
 		return
 	}
 
@@ -49,7 +71,7 @@ func (m *FileManagerState) navigateBack() {
 	}
 
 	baseName := filepath.Base(m.Cwd)
-	for i, f := range readFiles(parent, m) {
+	for i, f := range readFiles(m.fs, parent) {
 		if f.Name() == baseName {
 			m.cursorPositions[parent] = i
 			break
@@ -58,98 +80,34 @@ func (m *FileManagerState) navigateBack() {
 
 	m.cursorPositions[m.Cwd] = m.cursor
 	m.Cwd = parent
-	m.files = readFiles(parent, m)
-
-	if pos, exists := m.cursorPositions[parent]; exists {
-		if pos < len(m.files) {
-			m.cursor = pos
-			m.offset = min(m.cursor-m.visibleItems+1, len(m.files)-m.visibleItems)
-		} else {
-			m.cursor = 0
-			m.offset = 0
-		}
-	} else {
-		m.cursor = 0
-		m.offset = 0
-	}
+	m.refreshFiles()
+	m.restoreCursor(parent)
 
 	m.preview = false
 }
 
 func (m *FileManagerState) handleEnter() (tea.Model, tea.Cmd) {
+	if len(m.files) == 0 {
+		return m, nil
+	}
 	selected := m.files[m.cursor]
 
 	if selected.IsDir() {
-		m.cursorPositions[m.Cwd] = m.cursor
-		newPath := filepath.Join(m.Cwd, selected.Name())
-		m.Cwd = newPath
-		m.files = readFiles(newPath, m)
-
-		if pos, exists := m.cursorPositions[newPath]; exists {
-			if pos < len(m.files) {
-				m.cursor = pos
-				m.offset = min(m.cursor-m.visibleItems+1, len(m.files)-m.visibleItems)
-			} else {
-				m.cursor = 0
-				m.offset = 0
-			}
+		m.cursorPositions[m.displayPath()] = m.cursor
+		if layer := m.topArchive(); layer != nil {
+			layer.dir = path.Join(layer.dir, selected.Name())
 		} else {
-			m.cursor = 0
-			m.offset = 0
+			m.Cwd = filepath.Join(m.Cwd, selected.Name())
 		}
+		m.refreshFiles()
+		m.restoreCursor(m.displayPath())
 
-	} else if utils.IsZipArchive(selected.Name()) {
-		m.prevCursorPos = m.cursor
-		archivePath := filepath.Join(m.Cwd, selected.Name())
-
-		if m.isRemote {
-			if m.RemoteArchiveFile != nil {
-				m.RemoteArchiveFile.Close()
-				m.RemoteArchiveFile = nil
-			}
-
-			file, err := m.SftpClient.Open(filepath.ToSlash(archivePath))
-			if err != nil {
-				return m, tea.Println("Ошибка открытия архива:", err)
-			}
-
-			stat, err := file.Stat()
-			if err != nil {
-				file.Close()
-				return m, tea.Println("Ошибка получения информации об архиве:", err)
-			}
-
-			reader, err := zip.NewReader(file, stat.Size())
-			if err != nil {
-				file.Close()
-				return m, tea.Println("Ошибка чтения архива:", err)
-			}
-
-			m.inArchive = true
-			m.archivePath = archivePath
-			m.ArchiveFiles = reader.File
-			m.RemoteArchiveFile = file
-			m.files = readFiles("", m)
-		} else {
-			if m.ArchiveReader != nil {
-				m.ArchiveReader.Close()
-				m.ArchiveReader = nil
-			}
-
-			reader, err := zip.OpenReader(archivePath)
-			if err != nil {
-				return m, tea.Println("Ошибка чтения архива:", err)
-			}
-
-			m.inArchive = true
-			m.archivePath = archivePath
-			m.ArchiveFiles = reader.File
-			m.ArchiveReader = reader
-			m.files = readFiles("", m)
+	} else if vfs.IsArchive(m.activeFS(), m.entryPath(selected.Name()), selected) {
+		if err := m.pushArchive(selected.Name()); err != nil {
+			return m, tea.Println("Ошибка чтения архива:", err)
 		}
-
-		m.cursor = 0
-		m.offset = 0
+		m.refreshFiles()
+		m.restoreCursor(m.displayPath())
 	} else {
 		m.loadPreview()
 		m.preview = true
@@ -1,11 +1,13 @@
 package service
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"github.com/KharpukhaevV/filemanager/models"
 	"github.com/KharpukhaevV/filemanager/utils"
+	"github.com/KharpukhaevV/filemanager/vfs"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -23,49 +25,36 @@ func (m *FileManagerState) loadPreview() {
 	var err error
 	var fileName string
 	selected := m.files[m.cursor]
-	if utils.IsUnsupportedFile(selected.Name(), selected) {
+	innerName, compression := vfs.CompressedStream(selected.Name())
+	if utils.IsUnsupportedFile(innerName, selected) {
 		m.previewView.SetContent("Формат файла не поддерживается для просмотра")
 		return
 	}
 
-	if m.inArchive {
-		file := m.ArchiveFiles[m.cursor]
-		fileName = file.Name
-		rc, err := file.Open()
-		if err != nil {
-			m.previewView.SetContent(fmt.Sprintf("Ошибка открытия файла: %v", err))
-			return
-		}
-		defer rc.Close()
+	fileName = innerName
+	// Без сжатия превью нужен только первый MaxPreviewSize+1 байт (лишний байт
+	// показывает, что файл обрезан); сжатый поток распаковывается с начала
+	var file vfs.File
+	if compression == vfs.CompressionNone {
+		file, err = vfs.OpenHead(m.activeFS(), m.entryPath(selected.Name()), models.MaxPreviewSize+1)
+	} else {
+		file, err = m.activeFS().Open(m.entryPath(selected.Name()))
+	}
+	if err != nil {
+		m.previewView.SetContent(fmt.Sprintf("Ошибка открытия файла: %v", err))
+		return
+	}
+	defer file.Close()
 
-		content, err = io.ReadAll(rc)
-		if err != nil {
-			m.previewView.SetContent(fmt.Sprintf("Ошибка чтения файла: %v", err))
-			return
-		}
+	var truncated bool
+	if compression != vfs.CompressionNone {
+		content, truncated, err = readCompressed(file)
 	} else {
-		filePath := filepath.Join(m.Cwd, m.files[m.cursor].Name())
-		fileName = m.files[m.cursor].Name()
-
-		if m.isRemote {
-			file, err := m.SftpClient.Open(filepath.ToSlash(filePath))
-			if err != nil {
-				m.previewView.SetContent(fmt.Sprintf("Ошибка открытия файла: %v", err))
-				return
-			}
-			defer file.Close()
-			content, err = io.ReadAll(file)
-			if err != nil {
-				m.previewView.SetContent(fmt.Sprintf("Ошибка чтения файла: %v", err))
-				return
-			}
-		} else {
-			content, err = os.ReadFile(filePath)
-			if err != nil {
-				m.previewView.SetContent(fmt.Sprintf("Ошибка чтения файла: %v", err))
-				return
-			}
-		}
+		content, truncated, err = readLimited(file, models.MaxPreviewSize)
+	}
+	if err != nil {
+		m.previewView.SetContent(fmt.Sprintf("Ошибка чтения файла: %v", err))
+		return
 	}
 
 	contentStr := string(content)
@@ -89,12 +78,50 @@ func (m *FileManagerState) loadPreview() {
 	}
 
 	highlighted := utils.HighlightSyntax(contentStr, fileName)
+	switch {
+	case truncated && compression != vfs.CompressionNone:
+		highlighted += fmt.Sprintf("\n\n... показаны первые %s распакованных данных", utils.FormatSize(models.MaxDecompressedPreview))
+	case truncated:
+		highlighted += fmt.Sprintf("\n\n... показаны первые %s файла", utils.FormatSize(models.MaxPreviewSize))
+	}
 	m.previewView.SetContent(highlighted)
 	m.previewView.GotoTop()
 	m.previewContent = contentStr
 	m.previewFile = fileName
 }
 
+// readCompressed распаковывает одиночный сжатый поток, ограничивая объём результата.
+// Алгоритм определяется по сигнатуре, а не по расширению.
+func readCompressed(r io.Reader) ([]byte, bool, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(6)
+	compression := vfs.DetectCompression(header)
+	if compression == vfs.CompressionNone {
+		return nil, false, errors.New("файл не является сжатым потоком")
+	}
+
+	dr, err := vfs.Decompress(br, compression)
+	if err != nil {
+		return nil, false, err
+	}
+	defer dr.Close()
+
+	return readLimited(dr, models.MaxDecompressedPreview)
+}
+
+// readLimited читает не больше limit байт; второй результат сообщает,
+// что данные были обрезаны
+func readLimited(r io.Reader, limit int64) ([]byte, bool, error) {
+	content, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(content)) > limit {
+		return content[:limit], true, nil
+	}
+	return content, false, nil
+}
+
 func (m *FileManagerState) findMatches(query string) []int {
 	if query == "" {
 		return nil
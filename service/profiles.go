@@ -0,0 +1,607 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/models"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Профили подключений =====================
+
+// loadSFTPProfiles читает профили. Если файла ещё нет, в профиль переносится
+// подключение, сохранённое в sftp_config.json прежними версиями.
+func loadSFTPProfiles() ([]models.SFTPProfile, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(homeDir, models.SFTPProfilesFile))
+	if os.IsNotExist(err) {
+		return migrateSFTPConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []models.SFTPProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func saveSFTPProfiles(profiles []models.SFTPProfile) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(homeDir, models.SFTPProfilesFile)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0600)
+}
+
+// migrateSFTPConfig переносит единственное сохранённое подключение в профиль
+// и убирает его из sftp_config.json, оставляя общие настройки SSH
+func migrateSFTPConfig() ([]models.SFTPProfile, error) {
+	config, err := loadSFTPConfig()
+	if err != nil || config.Host == "" {
+		return nil, nil
+	}
+
+	profiles := []models.SFTPProfile{{
+		Name:     profileName(config.Host, config.User),
+		Host:     config.Host,
+		User:     config.User,
+		Password: config.Password,
+	}}
+	if err := saveSFTPProfiles(profiles); err != nil {
+		return nil, err
+	}
+	config.Host, config.User, config.Password = "", "", ""
+	return profiles, saveSFTPConfig(config)
+}
+
+// profileName возвращает имя профиля по умолчанию: user@host или host
+func profileName(host, user string) string {
+	if user == "" {
+		return host
+	}
+	return user + "@" + host
+}
+
+// profileAddress возвращает адрес профиля в формате запроса хоста
+func profileAddress(profile *models.SFTPProfile) string {
+	if profile.Port == 0 || strings.Contains(profile.Host, "://") {
+		return profile.Host
+	}
+	return net.JoinHostPort(profile.Host, strconv.Itoa(profile.Port))
+}
+
+// updateSFTPProfile применяет fn к профилю с указанным именем и сохраняет список
+func updateSFTPProfile(name string, fn func(profile *models.SFTPProfile)) error {
+	profiles, err := loadSFTPProfiles()
+	if err != nil {
+		return err
+	}
+	for i := range profiles {
+		if profiles[i].Name == name {
+			fn(&profiles[i])
+			return saveSFTPProfiles(profiles)
+		}
+	}
+	return nil
+}
+
+// rememberConnection вызывается после успешного входа. Подключение, введённое
+// вручную, сохраняется как профиль user@host, чтобы к нему можно было
+// вернуться из списка; введённый пароль или secret key S3 предлагается
+// сохранить в хранилище паролей.
+func (m *FileManagerState) rememberConnection() (tea.Model, tea.Cmd) {
+	target, err := parseRemoteTarget(m.remoteHost)
+	if err != nil {
+		return m, nil
+	}
+	if target.isS3() {
+		return m.storeSecret(s3Secret(target.name), m.remotePassword)
+	}
+	if m.profile != nil {
+		if m.remotePassword == m.profile.Password {
+			return m, nil
+		}
+		return m.storeSecret(profileSecret(m.profile.Name), m.remotePassword)
+	}
+
+	profiles, err := loadSFTPProfiles()
+	if err != nil {
+		m.status = fmt.Sprintf("Не удалось сохранить профиль: %v", err)
+		return m, nil
+	}
+	profile := models.SFTPProfile{
+		Name: profileName(m.remoteHost, m.remoteUser),
+		Host: m.remoteHost,
+		User: m.remoteUser,
+	}
+	found := false
+	for i := range profiles {
+		if profiles[i].Name == profile.Name {
+			profile = profiles[i]
+			found = true
+			break
+		}
+	}
+	if !found {
+		profiles = append(profiles, profile)
+		if err := saveSFTPProfiles(profiles); err != nil {
+			m.status = fmt.Sprintf("Не удалось сохранить профиль: %v", err)
+			return m, nil
+		}
+	}
+	m.profile = &profile
+	return m.storeSecret(profileSecret(profile.Name), m.remotePassword)
+}
+
+// saveProfileDir запоминает текущую директорию активного профиля
+func (m *FileManagerState) saveProfileDir() {
+	if m.profile == nil || !m.isRemote {
+		return
+	}
+	dir := m.Cwd
+	if err := updateSFTPProfile(m.profile.Name, func(profile *models.SFTPProfile) {
+		profile.LastDir = dir
+	}); err != nil {
+		m.status = fmt.Sprintf("Не удалось сохранить директорию профиля: %v", err)
+	}
+}
+
+// profileStartDir возвращает директорию, с которой начинается работа с
+// профилем: последнюю посещённую, если она ещё существует, или директорию
+// по умолчанию
+func (m *FileManagerState) profileStartDir() string {
+	for _, dir := range []string{m.profile.LastDir, m.profile.Dir} {
+		if dir == "" {
+			continue
+		}
+		if info, err := m.fs.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// ===================== Выбор профиля =====================
+
+// openProfiles показывает список профилей; ввод фильтрует его
+func (m *FileManagerState) openProfiles() {
+	profiles, err := loadSFTPProfiles()
+	m.status = ""
+	if err != nil {
+		m.status = fmt.Sprintf("Ошибка чтения профилей: %v", err)
+	}
+	m.profiles = profiles
+	m.profileForm = nil
+	m.mode = "profiles"
+	m.input = ""
+	m.profileCursor = 0
+	if len(profiles) > 0 {
+		m.profileCursor = 1
+	}
+}
+
+// inProfiles сообщает, открыт ли список профилей или его форма
+func (m *FileManagerState) inProfiles() bool {
+	return m.mode == "profiles" || m.mode == "profile_form" || m.mode == "profile_delete"
+}
+
+// filteredProfiles возвращает индексы профилей, подходящих под фильтр
+func (m *FileManagerState) filteredProfiles() []int {
+	filter := m.input
+	if m.mode != "profiles" {
+		filter = m.profileFilter
+	}
+	filter = strings.ToLower(filter)
+	var indexes []int
+	for i, profile := range m.profiles {
+		text := strings.ToLower(profile.Name + " " + profile.Host + " " + profile.User)
+		if strings.Contains(text, filter) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// selectedProfile возвращает индекс выбранного профиля в m.profiles или -1,
+// если выбран пункт «Новое подключение»
+func (m *FileManagerState) selectedProfile() int {
+	indexes := m.filteredProfiles()
+	if m.profileCursor < 1 || m.profileCursor > len(indexes) {
+		return -1
+	}
+	return indexes[m.profileCursor-1]
+}
+
+func (m *FileManagerState) updateProfilePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	count := len(m.filteredProfiles()) + 1
+
+	switch msg.String() {
+	case "esc":
+		m.mode = "normal"
+		m.input = ""
+	case "up":
+		if m.profileCursor > 0 {
+			m.profileCursor--
+		}
+	case "down":
+		if m.profileCursor < count-1 {
+			m.profileCursor++
+		}
+	case "enter":
+		index := m.selectedProfile()
+		m.input = ""
+		if index < 0 {
+			m.profile = nil
+			m.mode = "sftp_host"
+			return m, nil
+		}
+		return m.connectProfile(m.profiles[index])
+	case "ctrl+a":
+		m.startProfileForm(-1)
+	case "ctrl+e":
+		if index := m.selectedProfile(); index >= 0 {
+			m.startProfileForm(index)
+		}
+	case "ctrl+d":
+		if m.selectedProfile() >= 0 {
+			m.profileFilter = m.input
+			m.mode = "profile_delete"
+			m.input = ""
+		}
+	case "backspace":
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+			m.profileCursor = min(1, len(m.filteredProfiles()))
+		}
+	default:
+		if len(msg.String()) == 1 {
+			m.input += msg.String()
+			m.profileCursor = min(1, len(m.filteredProfiles()))
+		}
+	}
+	return m, nil
+}
+
+// connectProfile подключается по профилю. Сохранённый пароль берётся из
+// хранилища; запрашивается он, только если выбран вход по паролю, а пароль
+// не сохранён.
+func (m *FileManagerState) connectProfile(profile models.SFTPProfile) (tea.Model, tea.Cmd) {
+	m.profile = &profile
+	m.remoteHost = profileAddress(&profile)
+	m.remoteUser = profile.User
+	return m.withSecret(profileSecret(profile.Name), func(secret string) (tea.Model, tea.Cmd) {
+		m.remotePassword = secret
+		if secret == "" {
+			m.remotePassword = profile.Password
+		}
+		if profile.AuthMethod == "password" && m.remotePassword == "" {
+			m.mode = "sftp_password"
+			return m, nil
+		}
+		return m.startConnect()
+	})
+}
+
+// deleteProfile удаляет выбранный профиль после подтверждения и возвращает его имя
+func (m *FileManagerState) deleteProfile() string {
+	index := m.selectedProfile()
+	if index < 0 {
+		return ""
+	}
+	name := m.profiles[index].Name
+	profiles := append(m.profiles[:index:index], m.profiles[index+1:]...)
+	if err := saveSFTPProfiles(profiles); err != nil {
+		m.status = fmt.Sprintf("Не удалось удалить профиль: %v", err)
+		return ""
+	}
+	m.profiles = profiles
+	m.profileCursor = min(m.profileCursor, len(m.filteredProfiles()))
+	m.status = fmt.Sprintf("Профиль %s удалён", name)
+	return name
+}
+
+func (m *FileManagerState) renderProfiles() string {
+	var sb strings.Builder
+	sb.WriteString(models.Stls.Header.Render("Профили подключений") + "\n")
+
+	indexes := m.filteredProfiles()
+	rows := []string{"+ Новое подключение"}
+	for _, i := range indexes {
+		profile := m.profiles[i]
+		rows = append(rows, fmt.Sprintf("%s  (%s)", profile.Name, profileName(profileAddress(&profile), profile.User)))
+	}
+	for i, row := range rows {
+		style := models.Stls.Row
+		if i == m.profileCursor {
+			style = models.Stls.Selected
+		}
+		sb.WriteString(style.Render(row) + "\n")
+	}
+	return sb.String()
+}
+
+// renderProfileDetails показывает параметры выбранного профиля
+func (m *FileManagerState) renderProfileDetails() string {
+	var profile models.SFTPProfile
+	if m.mode == "profile_form" {
+		profile = m.profileForm.draft
+	} else if index := m.selectedProfile(); index >= 0 {
+		profile = m.profiles[index]
+	} else {
+		return "Ввести адрес, логин и пароль вручную"
+	}
+	auth := profile.AuthMethod
+	if auth == "" {
+		auth = "auto"
+	}
+	lines := []string{
+		"Имя: " + profile.Name,
+		"Адрес: " + profileAddress(&profile),
+		"Логин: " + profile.User,
+		"Вход: " + auth,
+	}
+	if profile.IdentityFile != "" {
+		lines = append(lines, "Ключ: "+profile.IdentityFile)
+	}
+	if profile.ProxyJump != "" {
+		lines = append(lines, "Переход: "+profile.ProxyJump)
+	}
+	if profile.Dir != "" {
+		lines = append(lines, "Директория: "+profile.Dir)
+	}
+	if profile.LastDir != "" {
+		lines = append(lines, "Последняя директория: "+profile.LastDir)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ===================== Редактирование профиля =====================
+
+// profileForm — профиль, который заполняется по одному полю за шаг
+type profileForm struct {
+	index int // индекс в m.profiles или -1 для нового профиля
+	draft models.SFTPProfile
+	field int
+}
+
+type profileField struct {
+	prompt string
+	get    func(p *models.SFTPProfile) string
+	set    func(p *models.SFTPProfile, value string) error
+	skip   func(p *models.SFTPProfile) bool
+	secret bool // ввод скрывается
+}
+
+var authMethodNames = map[string]bool{
+	"": true, "auto": true, "password": true, "publickey": true, "agent": true, "keyboard-interactive": true,
+}
+
+// profileUsesPassword сообщает, может ли профиль входить по паролю; пароль
+// keyboard-interactive отправляется в ответ на вопрос сервера о пароле
+func profileUsesPassword(p *models.SFTPProfile) bool {
+	return p.AuthMethod == "" || p.AuthMethod == "password" || p.AuthMethod == "keyboard-interactive"
+}
+
+var profileFields = []profileField{
+	{
+		prompt: "Имя профиля:",
+		get:    func(p *models.SFTPProfile) string { return p.Name },
+		set: func(p *models.SFTPProfile, value string) error {
+			if value == "" {
+				return fmt.Errorf("имя профиля не может быть пустым")
+			}
+			p.Name = value
+			return nil
+		},
+	},
+	{
+		prompt: "Хост (host, алиас ssh или ftp://, dav://...):",
+		get:    func(p *models.SFTPProfile) string { return p.Host },
+		set: func(p *models.SFTPProfile, value string) error {
+			if _, err := parseRemoteTarget(value); err != nil {
+				return err
+			}
+			p.Host = value
+			return nil
+		},
+	},
+	{
+		prompt: "Порт (Enter — по умолчанию):",
+		get: func(p *models.SFTPProfile) string {
+			if p.Port == 0 {
+				return ""
+			}
+			return strconv.Itoa(p.Port)
+		},
+		set: func(p *models.SFTPProfile, value string) error {
+			if value == "" {
+				p.Port = 0
+				return nil
+			}
+			port, err := strconv.Atoi(value)
+			if err != nil || port < 1 || port > 65535 {
+				return fmt.Errorf("неверный порт: %s", value)
+			}
+			p.Port = port
+			return nil
+		},
+		skip: func(p *models.SFTPProfile) bool { return strings.Contains(p.Host, "://") },
+	},
+	{
+		prompt: "Логин (Enter — из ~/.ssh/config):",
+		get:    func(p *models.SFTPProfile) string { return p.User },
+		set:    func(p *models.SFTPProfile, value string) error { p.User = value; return nil },
+	},
+	{
+		prompt: "Способ входа (auto, password, publickey, agent, keyboard-interactive):",
+		get:    func(p *models.SFTPProfile) string { return p.AuthMethod },
+		set: func(p *models.SFTPProfile, value string) error {
+			if !authMethodNames[value] {
+				return fmt.Errorf("неизвестный способ входа: %s", value)
+			}
+			if value == "auto" {
+				value = ""
+			}
+			p.AuthMethod = value
+			return nil
+		},
+	},
+	{
+		prompt: "Файл ключа (Enter — ключи по умолчанию):",
+		get:    func(p *models.SFTPProfile) string { return p.IdentityFile },
+		set:    func(p *models.SFTPProfile, value string) error { p.IdentityFile = value; return nil },
+		skip:   func(p *models.SFTPProfile) bool { return p.AuthMethod != "publickey" },
+	},
+	{
+		// Пароль не показывается; он сохраняется в хранилище, а не в профиле
+		prompt: "Пароль (Enter — без изменений):",
+		get:    func(p *models.SFTPProfile) string { return "" },
+		set: func(p *models.SFTPProfile, value string) error {
+			if value != "" {
+				p.Password = value
+			}
+			return nil
+		},
+		skip: func(p *models.SFTPProfile) bool {
+			return !profileUsesPassword(p) || credentialsDisabled()
+		},
+		secret: true,
+	},
+	{
+		prompt: "Директория по умолчанию (Enter — корень):",
+		get:    func(p *models.SFTPProfile) string { return p.Dir },
+		set:    func(p *models.SFTPProfile, value string) error { p.Dir = value; return nil },
+	},
+	{
+		prompt: "Переход ProxyJump (Enter — без перехода):",
+		get:    func(p *models.SFTPProfile) string { return p.ProxyJump },
+		set:    func(p *models.SFTPProfile, value string) error { p.ProxyJump = value; return nil },
+		skip:   func(p *models.SFTPProfile) bool { return strings.Contains(p.Host, "://") },
+	},
+}
+
+// startProfileForm начинает добавление (index < 0) или изменение профиля
+func (m *FileManagerState) startProfileForm(index int) {
+	form := &profileForm{index: index}
+	if index >= 0 {
+		form.draft = m.profiles[index]
+	}
+	m.profileForm = form
+	m.profileFilter = m.input
+	m.mode = "profile_form"
+	m.input = profileFields[0].get(&form.draft)
+}
+
+// submitProfileField принимает значение текущего поля и переходит к следующему;
+// после последнего поля профиль сохраняется
+func (m *FileManagerState) submitProfileField() (tea.Model, tea.Cmd) {
+	form := m.profileForm
+	field := profileFields[form.field]
+	value := m.input
+	if !field.secret {
+		value = strings.TrimSpace(value)
+	}
+	if err := field.set(&form.draft, value); err != nil {
+		m.status = err.Error()
+		return m, nil
+	}
+	if form.field == 0 {
+		for i, profile := range m.profiles {
+			if profile.Name == value && i != form.index {
+				m.status = fmt.Sprintf("Профиль %s уже существует", value)
+				return m, nil
+			}
+		}
+	}
+	m.status = ""
+
+	for form.field++; form.field < len(profileFields); form.field++ {
+		if next := profileFields[form.field]; next.skip == nil || !next.skip(&form.draft) {
+			m.input = next.get(&form.draft)
+			return m, nil
+		}
+	}
+
+	// Поля, скрытые выбранным способом входа, не сохраняются
+	if form.draft.AuthMethod != "publickey" {
+		form.draft.IdentityFile = ""
+	}
+	usesPassword := profileUsesPassword(&form.draft)
+	secret := ""
+	if usesPassword {
+		secret = form.draft.Password
+	}
+	form.draft.Password = ""
+	oldName := ""
+	if form.index >= 0 {
+		oldName = m.profiles[form.index].Name
+	}
+
+	profiles := append([]models.SFTPProfile(nil), m.profiles...)
+	if form.index >= 0 {
+		profiles[form.index] = form.draft
+	} else {
+		profiles = append(profiles, form.draft)
+	}
+	if err := saveSFTPProfiles(profiles); err != nil {
+		m.status = fmt.Sprintf("Не удалось сохранить профиль: %v", err)
+		return m, nil
+	}
+
+	name := form.draft.Name
+	m.openProfiles()
+	for i, index := range m.filteredProfiles() {
+		if m.profiles[index].Name == name {
+			m.profileCursor = i + 1
+		}
+	}
+	m.status = fmt.Sprintf("Профиль %s сохранён", name)
+
+	// Пароль переносится вместе с профилем при переименовании и удаляется,
+	// если вход по паролю больше не используется
+	stored := oldName != "" && vaultHasSecret(profileSecret(oldName))
+	if secret == "" && !stored {
+		return m, nil
+	}
+	return m.changeSecrets(func(secrets map[string]string) {
+		previous := secrets[profileSecret(oldName)]
+		delete(secrets, profileSecret(oldName))
+		if secret != "" {
+			previous = secret
+		}
+		if usesPassword && previous != "" {
+			secrets[profileSecret(name)] = previous
+		}
+	})
+}
+
+func (m *FileManagerState) profileFormPrompt() string {
+	prompt := profileFields[m.profileForm.field].prompt
+	if m.profileForm.index < 0 {
+		return "Новый профиль — " + prompt
+	}
+	return "Профиль " + m.profiles[m.profileForm.index].Name + " — " + prompt
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/KharpukhaevV/filemanager/models"
+	"github.com/KharpukhaevV/filemanager/utils"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Просмотр очереди передач =====================
+
+var jobStates = map[string]string{
+	"pending": "ожидает",
+	"active":  "идёт",
+	"paused":  "пауза",
+	"failed":  "ошибка",
+	"done":    "готово",
+}
+
+func (m *FileManagerState) inQueue() bool {
+	return m.mode == "transfers"
+}
+
+// openQueue показывает очередь передач вместо списка файлов
+func (m *FileManagerState) openQueue() {
+	m.mode = "transfers"
+	m.input = ""
+	m.queueCursor = min(m.queueCursor, max(len(m.queue)-1, 0))
+}
+
+// selectedJob возвращает задачу под курсором или nil для пустой очереди
+func (m *FileManagerState) selectedJob() *transferJob {
+	if m.queueCursor < 0 || m.queueCursor >= len(m.queue) {
+		return nil
+	}
+	return m.queue[m.queueCursor]
+}
+
+// updateQueue обрабатывает клавиши в очереди передач
+func (m *FileManagerState) updateQueue(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	job := m.selectedJob()
+
+	switch msg.String() {
+	case "esc", "t":
+		m.mode = "normal"
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		if m.queueCursor > 0 {
+			m.queueCursor--
+		}
+	case "down", "j":
+		if m.queueCursor < len(m.queue)-1 {
+			m.queueCursor++
+		}
+	case "K":
+		m.moveJob(-1)
+	case "J":
+		m.moveJob(1)
+	case " ", "p":
+		if job == nil {
+			break
+		}
+		switch job.State {
+		case "active":
+			m.stopTransfer("pause")
+		case "pending":
+			job.State = "paused"
+			m.saveTransfers()
+		case "paused":
+			job.State = "pending"
+			m.saveTransfers()
+			return m, m.runQueue()
+		}
+	case "r":
+		if job != nil && (job.State == "failed" || job.State == "paused") {
+			job.State = "pending"
+			job.Error = ""
+			m.saveTransfers()
+			return m, m.runQueue()
+		}
+	case "d":
+		if job == nil {
+			break
+		}
+		if job.State == "active" {
+			m.stopTransfer("cancel")
+			break
+		}
+		m.removeJob(job)
+		m.queueCursor = min(m.queueCursor, max(len(m.queue)-1, 0))
+		m.saveTransfers()
+	case "c":
+		// Завершённые задачи убираются из списка
+		for _, done := range slices.Clone(m.queue) {
+			if done.State == "done" {
+				m.removeJob(done)
+			}
+		}
+		m.queueCursor = min(m.queueCursor, max(len(m.queue)-1, 0))
+		m.saveTransfers()
+	}
+	return m, nil
+}
+
+// moveJob сдвигает выбранную задачу в очереди на delta позиций
+func (m *FileManagerState) moveJob(delta int) {
+	to := m.queueCursor + delta
+	if m.selectedJob() == nil || to < 0 || to >= len(m.queue) {
+		return
+	}
+	m.queue[m.queueCursor], m.queue[to] = m.queue[to], m.queue[m.queueCursor]
+	m.queueCursor = to
+	m.saveTransfers()
+}
+
+// renderQueue выводит список задач очереди
+func (m *FileManagerState) renderQueue() string {
+	var sb strings.Builder
+	sb.WriteString(models.Stls.Header.Render("Очередь передач") + "\n")
+	if len(m.queue) == 0 {
+		sb.WriteString(models.Stls.Row.Render("Очередь пуста") + "\n")
+		return sb.String()
+	}
+
+	for i, job := range m.queue {
+		direction := "↓"
+		if job.Kind == "upload" {
+			direction = "↑"
+		}
+		row := fmt.Sprintf("%s %-7s %3d%%  %s", direction, jobStates[job.State],
+			percent(job.Done, job.Size), filepath.Base(job.Source))
+		style := models.Stls.Row
+		if i == m.queueCursor {
+			style = models.Stls.Selected
+		}
+		sb.WriteString(style.Render(row) + "\n")
+	}
+	return sb.String()
+}
+
+// renderJobDetails показывает параметры выбранной задачи
+func (m *FileManagerState) renderJobDetails() string {
+	job := m.selectedJob()
+	if job == nil {
+		return "Скачивание — Ctrl+x, загрузка на сервер — u"
+	}
+
+	lines := []string{
+		"Тип: " + jobLabel(job),
+		"Состояние: " + jobStates[job.State],
+		"Откуда: " + job.Source,
+		"Куда: " + job.Dest,
+		fmt.Sprintf("Передано: %s из %s (%d%%)",
+			utils.FormatSize(job.Done), utils.FormatSize(job.Size), percent(job.Done, job.Size)),
+	}
+	if job.fsys != nil {
+		lines = append(lines, "Из архива: задача не сохраняется после выхода")
+	} else {
+		lines = append(lines, "Сервер: "+job.Host)
+	}
+	if m.active != nil && m.active.job == job {
+		if job.name != "" {
+			lines = append(lines, "Файл: "+job.name)
+		}
+		if speed, eta, ok := m.active.rate(); ok {
+			lines = append(lines, "Скорость: "+speed, "Осталось: "+eta)
+		}
+	}
+	if job.State == "pending" && m.jobFS(job) == nil {
+		lines = append(lines, "Ожидает подключения к "+job.Host)
+	}
+	if job.Error != "" {
+		lines = append(lines, "Ошибка: "+job.Error)
+	}
+	return strings.Join(lines, "\n")
+}
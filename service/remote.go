@@ -0,0 +1,289 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/KharpukhaevV/filemanager/vfs"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Удалённые подключения =====================
+
+// remoteTarget — разобранный адрес подключения
+type remoteTarget struct {
+	scheme string // sftp, ftp, ftps, dav, davs, s3 или s3+http
+	addr   string // host:port
+	dir    string // начальная директория
+	root   string // путь к корню ресурса WebDAV на сервере
+	name   string // хост в том виде, как он введён (имя профиля S3, алиас ssh)
+	region string // регион S3 из параметра ?region=
+	port   bool   // порт указан в адресе явно
+}
+
+var defaultPorts = map[string]string{
+	"sftp":    "22",
+	"ftp":     "21",
+	"ftps":    "21",
+	"dav":     "80",
+	"davs":    "443",
+	"s3":      "443",
+	"s3+http": "80",
+}
+
+// Синонимы схем WebDAV
+var schemeAliases = map[string]string{
+	"http":    "dav",
+	"https":   "davs",
+	"webdav":  "dav",
+	"webdavs": "davs",
+}
+
+// parseRemoteTarget разбирает введённый адрес. Адрес без схемы ("host",
+// "host:port" или алиас из ~/.ssh/config) означает SFTP; FTP задаётся как ftp://host[:port][/dir],
+// FTP с явным TLS — как ftps://host[:port][/dir]. Для WebDAV
+// (dav://, davs://, http://, https://) путь в адресе — корень ресурса.
+// S3 задаётся как s3://имя-профиля или s3://host[:port]?region=...;
+// s3+http:// подключается без TLS (например, к локальному MinIO).
+func parseRemoteTarget(input string) (*remoteTarget, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, fmt.Errorf("не указан хост")
+	}
+
+	target := &remoteTarget{scheme: "sftp", addr: input, dir: "/"}
+	if strings.Contains(input, "://") {
+		u, err := url.Parse(input)
+		if err != nil {
+			return nil, fmt.Errorf("неверный адрес: %v", err)
+		}
+		target.scheme = strings.ToLower(u.Scheme)
+		if alias, ok := schemeAliases[target.scheme]; ok {
+			target.scheme = alias
+		}
+		target.addr = u.Host
+		target.name = u.Host
+		target.region = u.Query().Get("region")
+		switch {
+		case target.scheme == "dav" || target.scheme == "davs":
+			target.root = strings.TrimSuffix(u.Path, "/")
+		case u.Path != "":
+			target.dir = path.Clean(u.Path)
+		}
+	}
+
+	port, ok := defaultPorts[target.scheme]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемый протокол: %s", target.scheme)
+	}
+	if target.addr == "" {
+		return nil, fmt.Errorf("не указан хост")
+	}
+	if host, _, err := net.SplitHostPort(target.addr); err == nil {
+		target.port = true
+		if target.name == "" {
+			target.name = host
+		}
+	} else {
+		if target.name == "" {
+			target.name = strings.Trim(target.addr, "[]")
+		}
+		target.addr = net.JoinHostPort(strings.Trim(target.addr, "[]"), port)
+	}
+	return target, nil
+}
+
+func (t *remoteTarget) isS3() bool {
+	return t.scheme == "s3" || t.scheme == "s3+http"
+}
+
+// remoteDialDoneMsg сообщает о завершении подключения к FTP, WebDAV или S3
+type remoteDialDoneMsg struct {
+	target *remoteTarget
+	fsys   vfs.FileSystem
+	status string
+	err    error
+}
+
+// connectRemote подключается к серверу FTP, WebDAV или S3 в фоне; результат
+// приходит сообщением remoteDialDoneMsg. SFTP подключается через startSFTP.
+func (m *FileManagerState) connectRemote(target *remoteTarget) tea.Cmd {
+	m.connectTarget = target
+	m.mode = "connecting"
+	user, password := m.remoteUser, m.remotePassword
+
+	return func() tea.Msg {
+		msg := remoteDialDoneMsg{target: target}
+		switch target.scheme {
+		case "ftp", "ftps":
+			msg.fsys, msg.err = dialFTP(target, user, password)
+		case "dav", "davs":
+			msg.fsys, msg.err = dialWebDAV(target, user, password)
+		case "s3", "s3+http":
+			msg.fsys, msg.status, msg.err = dialS3(target, user, password)
+		default:
+			msg.err = fmt.Errorf("неподдерживаемый протокол: %s", target.scheme)
+		}
+		return msg
+	}
+}
+
+// finishRemote переходит на сервер после подключения в фоне
+func (m *FileManagerState) finishRemote(msg remoteDialDoneMsg) (tea.Model, tea.Cmd) {
+	// Результат отменённого подключения отбрасывается
+	if msg.target != m.connectTarget {
+		if msg.fsys != nil {
+			msg.fsys.Close()
+		}
+		return m, nil
+	}
+	m.connectTarget = nil
+	if msg.status != "" {
+		m.status = msg.status
+	}
+	if msg.err != nil {
+		return m.finishConnect(msg.err)
+	}
+	m.fs = msg.fsys
+	m.enterRemote(msg.target)
+	return m.finishConnect(nil)
+}
+
+// cancelConnect прерывает подключение, которое ещё устанавливается
+func (m *FileManagerState) cancelConnect() tea.Cmd {
+	m.mode = "normal"
+	if m.connectTarget != nil {
+		m.connectTarget = nil
+		m.status = "Подключение отменено"
+		return nil
+	}
+	return m.cancelSSHDial()
+}
+
+// enterRemote переходит в начальную директорию подключения: для профиля —
+// в последнюю посещённую или директорию по умолчанию
+func (m *FileManagerState) enterRemote(target *remoteTarget) {
+	dir := target.dir
+	if m.profile != nil {
+		if profileDir := m.profileStartDir(); profileDir != "" {
+			dir = profileDir
+		}
+	}
+	m.isRemote = true
+	m.switchToRemote(dir)
+}
+
+// dialFTP подключается к FTP-серверу; для ftps:// включается явный TLS
+func dialFTP(target *remoteTarget, user, password string) (vfs.FileSystem, error) {
+	fsys, err := vfs.NewFTPFS(vfs.FTPConfig{
+		Addr:     target.addr,
+		User:     user,
+		Password: password,
+		TLS:      target.scheme == "ftps",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к серверу: %v", err)
+	}
+	return fsys, nil
+}
+
+// dialWebDAV подключается к WebDAV-ресурсу; davs:// работает поверх HTTPS
+func dialWebDAV(target *remoteTarget, user, password string) (vfs.FileSystem, error) {
+	scheme := "http"
+	if target.scheme == "davs" {
+		scheme = "https"
+	}
+	base := url.URL{Scheme: scheme, Host: target.addr, Path: target.root}
+
+	fsys, err := vfs.NewWebDAVFS(vfs.WebDAVConfig{
+		URL:      base.String(),
+		User:     user,
+		Password: password,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к серверу: %v", err)
+	}
+	return fsys, nil
+}
+
+// startConnect подключается к m.remoteHost
+func (m *FileManagerState) startConnect() (tea.Model, tea.Cmd) {
+	m.input = ""
+	target, err := parseRemoteTarget(m.remoteHost)
+	if err != nil {
+		return m.finishConnect(err)
+	}
+	if target.scheme == "sftp" {
+		return m.startSFTP(target)
+	}
+	return m, m.connectRemote(target)
+}
+
+// finishConnect возвращает в обычный режим после подключения; если нужна
+// парольная фраза ключа SSH или подтверждение ключа неизвестного сервера,
+// переключает ввод на соответствующий запрос
+func (m *FileManagerState) finishConnect(err error) (tea.Model, tea.Cmd) {
+	var locked *errKeyPassphrase
+	if errors.As(err, &locked) {
+		m.pendingKey = locked.path
+		m.mode = "ssh_passphrase"
+		return m, nil
+	}
+	var unknown *errUnknownHost
+	if errors.As(err, &unknown) {
+		m.pendingHostKey = unknown
+		m.mode = "host_key_confirm"
+		return m, nil
+	}
+	m.mode = "normal"
+	var mismatch *errHostKeyMismatch
+	if errors.As(err, &mismatch) {
+		m.status = "Ключ сервера изменился — подключение отклонено"
+		return m, tea.Println(err)
+	}
+	if err != nil {
+		return m, tea.Println("Ошибка подключения:", err)
+	}
+	// Задачи очереди для этого сервера продолжаются после подключения
+	model, cmd := m.rememberConnection()
+	return model, tea.Batch(cmd, m.runQueue())
+}
+
+func (m *FileManagerState) switchToRemote(dir string) {
+	m.Cwd = dir
+	m.refreshFiles()
+	m.cursorPositions = make(map[string]int)
+	m.marked = make(map[string]bool)
+}
+
+// disconnectRemote закрывает удалённое подключение и возвращает локальную файловую систему
+func (m *FileManagerState) disconnectRemote() {
+	m.stopTransfer("disconnect")
+	m.closeArchives()
+	m.saveProfileDir()
+	if m.isRemote {
+		m.fs.Close()
+	}
+	m.SftpClient = nil
+	if m.SftpSession != nil {
+		m.SftpSession.Close()
+		m.SftpSession = nil
+	}
+	closeSSHClients(m.sshClients)
+	m.sshClients = nil
+	m.fs = vfs.NewLocalFS()
+	m.isRemote = false
+	m.remoteHost = ""
+	m.remoteUser = ""
+	m.remotePassword = ""
+	m.profile = nil
+	m.keyPassphrases = nil
+	m.marked = make(map[string]bool)
+	m.Cwd, _ = os.Getwd()
+	m.refreshFiles()
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/models"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"os"
+	"path/filepath"
+)
+
+// ===================== Работа с S3 =====================
+
+func loadS3Profiles() ([]models.S3Profile, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(homeDir, models.S3ProfilesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var profiles []models.S3Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func saveS3Profiles(profiles []models.S3Profile) error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(homeDir, models.S3ProfilesFile)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0600)
+}
+
+// findS3Profile ищет сохранённый профиль по имени
+func findS3Profile(name string) (*models.S3Profile, bool) {
+	profiles, err := loadS3Profiles()
+	if err != nil {
+		return nil, false
+	}
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i], true
+		}
+	}
+	return nil, false
+}
+
+// storeS3Profile добавляет профиль или заменяет профиль с тем же именем
+func storeS3Profile(profile models.S3Profile) error {
+	profiles, err := loadS3Profiles()
+	if err != nil {
+		return err
+	}
+	for i := range profiles {
+		if profiles[i].Name == profile.Name {
+			profiles[i] = profile
+			return saveS3Profiles(profiles)
+		}
+	}
+	return saveS3Profiles(append(profiles, profile))
+}
+
+// dialS3 подключается по сохранённому профилю s3://имя или по адресу хранилища
+// с введёнными ключами; после успешного входа по адресу профиль сохраняется.
+// Ошибка сохранения профиля не прерывает подключение и возвращается как status.
+func dialS3(target *remoteTarget, accessKey, secretKey string) (vfs.FileSystem, string, error) {
+	profile, saved := findS3Profile(target.name)
+	if saved && profile.SecretKey == "" {
+		profile.SecretKey = secretKey
+	}
+	if !saved {
+		profile = &models.S3Profile{
+			Name:      target.name,
+			Endpoint:  target.addr,
+			Region:    target.region,
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			Secure:    target.scheme == "s3",
+		}
+	}
+
+	fsys, err := vfs.NewS3FS(vfs.S3Config{
+		Endpoint:  profile.Endpoint,
+		Region:    profile.Region,
+		AccessKey: profile.AccessKey,
+		SecretKey: profile.SecretKey,
+		Secure:    profile.Secure,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("не удалось подключиться к хранилищу: %v", err)
+	}
+
+	// Secret key сохраняется не в профиле, а в хранилище паролей
+	var status string
+	if !saved {
+		stored := *profile
+		stored.SecretKey = ""
+		if err := storeS3Profile(stored); err != nil {
+			status = fmt.Sprintf("Не удалось сохранить профиль S3: %v", err)
+		}
+	}
+	return fsys, status, nil
+}
+
+// savedS3Profile возвращает сохранённый профиль S3, на который указывает
+// адрес; для него не нужно запрашивать access key
+func savedS3Profile(address string) (*models.S3Profile, bool) {
+	target, err := parseRemoteTarget(address)
+	if err != nil || !target.isS3() {
+		return nil, false
+	}
+	return findS3Profile(target.name)
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/KharpukhaevV/filemanager/models"
-	"github.com/KharpukhaevV/filemanager/utils"
-	"io"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	"net"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -49,75 +51,173 @@ func saveSFTPConfig(config *models.SFTPConfig) error {
 	}
 
 	configPath := filepath.Join(homeDir, models.ConfigFileName)
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
 	return os.WriteFile(configPath, data, 0600)
 }
 
-func (m *FileManagerState) readRemoteFiles(dir string) []os.FileInfo {
-	files, err := m.SftpClient.ReadDir(dir)
-	if err != nil {
-		fmt.Printf("Ошибка чтения директории %s: %v\n", dir, err)
+// sshHostConfigFor возвращает параметры из ~/.ssh/config для адреса SFTP
+func sshHostConfigFor(address string) *sshHostConfig {
+	target, err := parseRemoteTarget(address)
+	if err != nil || target.scheme != "sftp" {
 		return nil
 	}
+	return lookupSSHHost(target.name)
+}
+
+// completeHost дополняет введённый адрес алиасом из ~/.ssh/config. При
+// нескольких вариантах дописывается общая часть, а варианты выводятся в статус.
+func (m *FileManagerState) completeHost() {
+	var matches []string
+	for _, alias := range sshConfigAliases() {
+		if strings.HasPrefix(alias, m.input) {
+			matches = append(matches, alias)
+		}
+	}
 
-	utils.SortFiles(files)
-	return files
+	switch len(matches) {
+	case 0:
+		m.status = "Нет подходящих алиасов в ~/.ssh/config"
+	case 1:
+		m.input = matches[0]
+		m.status = ""
+	default:
+		m.input = commonPrefix(matches)
+		m.status = "Алиасы: " + strings.Join(matches, ", ")
+	}
+}
+
+// sshEndpoint — узел SSH после применения ~/.ssh/config
+type sshEndpoint struct {
+	addr      string // host:port для подключения
+	auth      *sshAuth
+	proxyJump string // переходы до узла в формате ProxyJump
 }
 
-func (m *FileManagerState) initSFTP() error {
-	config := &ssh.ClientConfig{
-		User: m.remoteUser,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(m.remotePassword),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+// resolveSSHTarget применяет ~/.ssh/config к введённому адресу: HostName,
+// Port, User, IdentityFile и ProxyJump берутся из подходящих блоков Host.
+// Явно введённые порт и логин, а также переходы из sftp_config.json важнее
+// конфигурации ssh; параметры активного профиля важнее всего остального.
+func (m *FileManagerState) resolveSSHTarget(target *remoteTarget) *sshEndpoint {
+	hostConfig := lookupSSHHost(target.name)
+	_, port, _ := net.SplitHostPort(target.addr)
+	if !target.port && hostConfig.Port != "" {
+		port = hostConfig.Port
+	}
+
+	endpoint := &sshEndpoint{
+		addr:      net.JoinHostPort(hostConfig.HostName, port),
+		auth:      &sshAuth{user: m.remoteUser, password: m.remotePassword},
+		proxyJump: hostConfig.ProxyJump,
+	}
+	if endpoint.auth.user == "" {
+		endpoint.auth.user = hostConfig.User
 	}
+	if endpoint.auth.user == "" {
+		endpoint.auth.user = localUserName()
+	}
+	if config, err := loadSFTPConfig(); err == nil {
+		endpoint.auth.identityFiles = config.IdentityFiles
+		endpoint.auth.methods = config.AuthMethods
+		if config.ProxyJump != "" {
+			endpoint.proxyJump = config.ProxyJump
+		}
+	}
+	endpoint.auth.identityFiles = append(endpoint.auth.identityFiles, hostConfig.IdentityFiles...)
+
+	if profile := m.profile; profile != nil {
+		// Keyboard-interactive остаётся доступным для второго фактора
+		if profile.AuthMethod != "" {
+			endpoint.auth.methods = []string{profile.AuthMethod, "keyboard-interactive"}
+		}
+		if profile.IdentityFile != "" {
+			endpoint.auth.identityFiles = append([]string{profile.IdentityFile}, endpoint.auth.identityFiles...)
+		}
+		if profile.ProxyJump != "" {
+			endpoint.proxyJump = profile.ProxyJump
+		}
+	}
+	return endpoint
+}
+
+// localUserName возвращает имя локального пользователя — логин SSH по умолчанию
+func localUserName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
 
-	client, err := ssh.Dial("tcp", m.remoteHost, config)
+// startSFTP подключается по SSH, пробуя ssh-agent, ключи, пароль и
+// keyboard-interactive в настроенном порядке. Соединение устанавливается в
+// фоне, чтобы интерфейс мог задавать вопросы сервера (например, код TOTP);
+// результат приходит сообщением sshDialDoneMsg. Если сервер отверг всё
+// остальное, а зашифрованный ключ ещё не расшифрован, возвращается
+// errKeyPassphrase, чтобы запросить парольную фразу. Ключ сервера сверяется
+// с known_hosts: для неизвестного сервера возвращается errUnknownHost,
+// изменившийся ключ отклоняет подключение. Если заданы переходы (ProxyJump),
+// соединение строится через них по цепочке.
+func (m *FileManagerState) startSFTP(target *remoteTarget) (tea.Model, tea.Cmd) {
+	endpoint := m.resolveSSHTarget(target)
+	chain, err := jumpEndpoints(endpoint)
 	if err != nil {
-		return fmt.Errorf("не удалось подключиться к серверу: %v", err)
+		return m.finishConnect(err)
 	}
 
+	m.mode = "connecting"
+	return m, m.startSSHDial(target, append(chain, endpoint))
+}
+
+// finishSFTP открывает SFTP поверх установленного соединения
+func (m *FileManagerState) finishSFTP(msg sshDialDoneMsg) (tea.Model, tea.Cmd) {
+	// Результат отменённого подключения отбрасывается
+	if msg.dial != m.sshDial {
+		closeSSHClients(msg.clients)
+		return m, nil
+	}
+	m.sshDial = nil
+	for _, path := range msg.rejected {
+		delete(m.keyPassphrases, path)
+		m.status = fmt.Sprintf("Неверная парольная фраза для ключа %s", path)
+	}
+	if msg.err != nil {
+		return m.finishConnect(msg.err)
+	}
+	if err := m.attachSFTP(msg.clients); err != nil {
+		return m.finishConnect(err)
+	}
+	m.enterRemote(msg.dial.target)
+	return m.finishConnect(nil)
+}
+
+// attachSFTP создаёт SFTP-клиент на целевом узле цепочки
+func (m *FileManagerState) attachSFTP(clients []*ssh.Client) error {
+	client := clients[len(clients)-1]
+
 	session, err := client.NewSession()
 	if err != nil {
+		closeSSHClients(clients)
 		return fmt.Errorf("не удалось создать сессию: %v", err)
 	}
 
 	sftpClient, err := sftp.NewClient(client)
 	if err != nil {
+		session.Close()
+		closeSSHClients(clients)
 		return fmt.Errorf("не удалось создать SFTP клиент: %v", err)
 	}
 
 	m.SftpClient = sftpClient
 	m.SftpSession = session
-	m.isRemote = true
-	m.switchToRemote()
+	m.sshClients = clients
+	m.fs = vfs.NewSFTPFS(sftpClient)
 
 	return nil
 }
 
-func (m *FileManagerState) switchToRemote() {
-	m.Cwd = "/"
-	m.files = m.readRemoteFiles(m.Cwd)
-	m.cursorPositions = make(map[string]int)
-}
-
-func (m *FileManagerState) disconnectSFTP() {
-	if m.SftpClient != nil {
-		m.SftpClient.Close()
-		m.SftpClient = nil
-	}
-	if m.SftpSession != nil {
-		m.SftpSession.Close()
-		m.SftpSession = nil
-	}
-	m.isRemote = false
-	m.remoteHost = ""
-	m.remoteUser = ""
-	m.remotePassword = ""
-	m.Cwd, _ = os.Getwd()
-	m.files = readFiles(m.Cwd, m)
-}
-
+// downloadFile ставит выбранный файл в очередь скачивания
+// в ~/.filemanager/downloads/ГГГГ/ММ/ДД
 func (m *FileManagerState) downloadFile() tea.Cmd {
 	// Проверяем, что курсор указывает на файл, а не папку
 	if m.cursor < 0 || m.cursor >= len(m.files) {
@@ -129,7 +229,7 @@ func (m *FileManagerState) downloadFile() tea.Cmd {
 		return tea.Println("Нельзя скачивать папки. Выберите файл.")
 	}
 
-	remotePath := filepath.ToSlash(filepath.Join(m.Cwd, selected.Name()))
+	remotePath := m.entryPath(selected.Name())
 
 	// Создаем структуру для папки загрузок
 	homeDir, err := os.UserHomeDir()
@@ -151,45 +251,18 @@ func (m *FileManagerState) downloadFile() tea.Cmd {
 		return tea.Println("Ошибка создания директории для скачивания:", err)
 	}
 
-	localPath := filepath.Join(downloadDir, selected.Name())
-
-	// Открываем файлы
-	remoteFile, err := m.SftpClient.Open(remotePath)
-	if err != nil {
-		return tea.Println("Ошибка открытия файла на сервере:", err)
-	}
-	defer remoteFile.Close()
-
-	localFile, err := os.Create(localPath)
-	if err != nil {
-		return tea.Println("Ошибка создания локального файла:", err)
-	}
-	defer localFile.Close()
-
-	// Копируем с прогрессом
-	fileSize := selected.Size()
-	buf := make([]byte, 32*1024) // 32KB буфер
-	var totalRead int64
-
-	for {
-		n, err := remoteFile.Read(buf)
-		if n > 0 {
-			if _, err := localFile.Write(buf[:n]); err != nil {
-				return tea.Println("Ошибка записи в файл:", err)
-			}
-			totalRead += int64(n)
-			progress := int(float64(totalRead) / float64(fileSize) * 100)
-			m.status = fmt.Sprintf("Скачивание %s: %d%%", selected.Name(), progress)
-		}
-
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return tea.Println("Ошибка чтения файла:", err)
-		}
+	job := &transferJob{TransferJob: models.TransferJob{
+		Kind:   "download",
+		Host:   m.remoteHost,
+		Source: remotePath,
+		Dest:   filepath.Join(downloadDir, selected.Name()),
+		Size:   selected.Size(),
+	}}
+	// Скачивание из архива читает открытый архив, который не закрывается до окончания
+	if m.inArchive() {
+		job.fsys = m.activeFS()
+		job.release = m.holdArchives()
 	}
-
-	m.status = fmt.Sprintf("Файл %s скачан в %s", selected.Name(), downloadDir)
-	return nil
+	m.status = fmt.Sprintf("%s добавлен в очередь передач", selected.Name())
+	return m.enqueue(job)
 }
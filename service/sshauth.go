@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// ===================== Аутентификация SSH =====================
+
+// Способы входа по умолчанию: как в ssh, но пароль пробуется раньше
+// keyboard-interactive, который обычно запрашивает второй фактор
+var defaultAuthMethods = []string{"agent", "publickey", "password", "keyboard-interactive"}
+
+// Ключи, которые ssh ищет в ~/.ssh, если IdentityFile не задан
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshAuth описывает, чем входить на SSH-сервер
+type sshAuth struct {
+	user          string
+	password      string
+	identityFiles []string // пустой список — ключи по умолчанию из ~/.ssh
+	methods       []string // agent, publickey, password, keyboard-interactive
+}
+
+// errKeyPassphrase сообщает, что сервер не принял доступные ключи и осталось
+// попробовать зашифрованный ключ, для которого нужна парольная фраза
+type errKeyPassphrase struct {
+	path string
+}
+
+func (e *errKeyPassphrase) Error() string {
+	return fmt.Sprintf("для ключа %s нужна парольная фраза", e.path)
+}
+
+// sshAuthSession — способы входа, собранные для одного подключения
+type sshAuthSession struct {
+	methods []ssh.AuthMethod
+	locked  []string // зашифрованные ключи без парольной фразы
+	agent   net.Conn // соединение с ssh-agent, закрывается после рукопожатия
+}
+
+func (s *sshAuthSession) Close() {
+	if s.agent != nil {
+		s.agent.Close()
+	}
+}
+
+// sshKeys — парольные фразы ключей для одного подключения. Подключение
+// идёт в фоне, поэтому работает с копией m.keyPassphrases; ключи с неверной
+// фразой возвращаются в sshDialDoneMsg и забываются уже в Update.
+type sshKeys struct {
+	passphrases map[string]string
+	rejected    []string // ключи, для которых введена неверная фраза
+}
+
+// sshAuthMethods собирает способы входа в заданном порядке. Ключи агента и
+// файлов объединяются в один метод publickey: клиент SSH не пробует метод
+// с тем же именем повторно. Keyboard-interactive всегда пробуется последним;
+// вопросы сервера передаются в prompt.
+func (k *sshKeys) sshAuthMethods(auth *sshAuth, prompt func(instruction string, questions []string, echos []bool) ([]string, error)) (*sshAuthSession, error) {
+	order := auth.methods
+	if len(order) == 0 {
+		order = defaultAuthMethods
+	}
+
+	session := &sshAuthSession{}
+	var signers []ssh.Signer
+	seen := make(map[string]bool)
+	addSigner := func(signer ssh.Signer) {
+		key := string(signer.PublicKey().Marshal())
+		if !seen[key] {
+			seen[key] = true
+			signers = append(signers, signer)
+		}
+	}
+	usePassword, passwordFirst, useKeyboard := false, false, false
+
+	for _, method := range order {
+		switch method {
+		case "agent":
+			for _, signer := range session.agentSigners() {
+				addSigner(signer)
+			}
+		case "publickey":
+			keys, err := k.identitySigners(auth.identityFiles, session)
+			if err != nil {
+				session.Close()
+				return nil, err
+			}
+			for _, signer := range keys {
+				addSigner(signer)
+			}
+		case "password":
+			if auth.password != "" && !usePassword {
+				usePassword = true
+				passwordFirst = len(signers) == 0 && len(session.locked) == 0
+			}
+		case "keyboard-interactive":
+			useKeyboard = true
+		default:
+			session.Close()
+			return nil, fmt.Errorf("неизвестный способ входа: %s", method)
+		}
+	}
+
+	if len(signers) > 0 {
+		session.methods = append(session.methods, ssh.PublicKeys(signers...))
+	}
+	if usePassword {
+		password := ssh.Password(auth.password)
+		if passwordFirst {
+			session.methods = append([]ssh.AuthMethod{password}, session.methods...)
+		} else {
+			session.methods = append(session.methods, password)
+		}
+	}
+	if useKeyboard {
+		session.methods = append(session.methods, ssh.KeyboardInteractive(keyboardChallenge(auth.password, prompt)))
+	}
+	return session, nil
+}
+
+// agentSigners возвращает ключи и сертификаты из ssh-agent по SSH_AUTH_SOCK
+func (s *sshAuthSession) agentSigners() []ssh.Signer {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" || s.agent != nil {
+		return nil
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil
+	}
+	s.agent = conn
+	return signers
+}
+
+// identitySigners загружает ключи из файлов. Рядом с ключом ищется сертификат
+// OpenSSH (<ключ>-cert.pub), который предлагается серверу первым.
+// Отсутствующие ключи по умолчанию пропускаются, явно указанные — ошибка.
+func (k *sshKeys) identitySigners(files []string, session *sshAuthSession) ([]ssh.Signer, error) {
+	explicit := len(files) > 0
+	if !explicit {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		for _, name := range defaultIdentityFiles {
+			files = append(files, filepath.Join(homeDir, ".ssh", name))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, path := range files {
+		path = expandHome(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !explicit && os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("не удалось прочитать ключ: %v", err)
+		}
+
+		signer, err := k.parseIdentity(path, data)
+		if err != nil {
+			return nil, err
+		}
+		if signer == nil {
+			// Ключ, пропущенный пользователем, больше не предлагается
+			if _, skipped := k.passphrases[path]; !skipped {
+				session.locked = append(session.locked, path)
+			}
+			continue
+		}
+
+		if cert, ok := loadCertificate(path + "-cert.pub"); ok {
+			if certSigner, err := ssh.NewCertSigner(cert, signer); err == nil {
+				signers = append(signers, certSigner)
+			}
+		}
+		signers = append(signers, signer)
+	}
+	return signers, nil
+}
+
+// parseIdentity разбирает закрытый ключ. Для зашифрованного ключа без
+// введённой парольной фразы возвращает nil без ошибки.
+func (k *sshKeys) parseIdentity(path string, data []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer, nil
+	}
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return nil, fmt.Errorf("не удалось разобрать ключ %s: %v", path, err)
+	}
+
+	passphrase, ok := k.passphrases[path]
+	if !ok || passphrase == "" {
+		// Пустая фраза означает, что пользователь решил пропустить ключ
+		return nil, nil
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	if err != nil {
+		delete(k.passphrases, path)
+		if errors.Is(err, x509.IncorrectPasswordError) {
+			k.rejected = append(k.rejected, path)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("не удалось расшифровать ключ %s: %v", path, err)
+	}
+	return signer, nil
+}
+
+// loadCertificate читает сертификат OpenSSH, если он есть
+func loadCertificate(path string) (*ssh.Certificate, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(bytes.TrimSpace(data))
+	if err != nil {
+		return nil, false
+	}
+	cert, ok := key.(*ssh.Certificate)
+	return cert, ok
+}
+
+// isAuthFailure отличает отказ в аутентификации от сетевых ошибок
+func isAuthFailure(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "unable to authenticate")
+}
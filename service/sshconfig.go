@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ===================== Конфигурация ssh =====================
+
+// Общая конфигурация ssh; читается после пользовательской
+const systemSSHConfig = "/etc/ssh/ssh_config"
+
+// Ограничение вложенности Include, как в OpenSSH
+const maxSSHConfigDepth = 16
+
+// sshHostConfig — параметры хоста из ~/.ssh/config
+type sshHostConfig struct {
+	HostName      string
+	Port          string
+	User          string
+	IdentityFiles []string
+	ProxyJump     string
+}
+
+// sshConfigPaths возвращает пользовательский и общий файлы конфигурации
+// вместе с директориями, от которых отсчитываются относительные Include
+func sshConfigPaths() [][2]string {
+	var paths [][2]string
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		sshDir := filepath.Join(homeDir, ".ssh")
+		paths = append(paths, [2]string{filepath.Join(sshDir, "config"), sshDir})
+	}
+	return append(paths, [2]string{systemSSHConfig, filepath.Dir(systemSSHConfig)})
+}
+
+// lookupSSHHost собирает параметры для алиаса так же, как ssh: действует
+// первое найденное значение, IdentityFile накапливаются. Блоки Match, кроме
+// "Match all", не поддерживаются и считаются неподходящими.
+func lookupSSHHost(alias string) *sshHostConfig {
+	return lookupSSHHostIn(sshConfigPaths(), alias)
+}
+
+// lookupSSHHostIn собирает параметры алиаса из заданных файлов конфигурации
+func lookupSSHHostIn(paths [][2]string, alias string) *sshHostConfig {
+	config := &sshHostConfig{}
+	for _, p := range paths {
+		readSSHConfig(p[0], p[1], 0, func(keyword string, args []string, patterns []string) bool {
+			if patterns != nil {
+				return matchHostPatterns(alias, patterns)
+			}
+			config.apply(keyword, args)
+			return true
+		})
+	}
+
+	config.HostName = expandSSHTokens(config.HostName, alias, alias, "")
+	if config.HostName == "" {
+		config.HostName = alias
+	}
+	for i, file := range config.IdentityFiles {
+		config.IdentityFiles[i] = expandSSHTokens(file, alias, config.HostName, config.User)
+	}
+	return config
+}
+
+func (c *sshHostConfig) apply(keyword string, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	switch keyword {
+	case "hostname":
+		if c.HostName == "" {
+			c.HostName = args[0]
+		}
+	case "port":
+		if _, err := strconv.Atoi(args[0]); err == nil && c.Port == "" {
+			c.Port = args[0]
+		}
+	case "user":
+		if c.User == "" {
+			c.User = args[0]
+		}
+	case "identityfile":
+		if !strings.EqualFold(args[0], "none") {
+			c.IdentityFiles = append(c.IdentityFiles, args[0])
+		}
+	case "proxyjump":
+		// "ProxyJump none" тоже запоминается: оно отменяет переходы из блоков ниже
+		if c.ProxyJump == "" {
+			c.ProxyJump = args[0]
+		}
+	}
+}
+
+// readSSHConfig читает файл конфигурации и раскрывает Include. Для строк Host
+// и Match fn вызывается со списком шаблонов и возвращает, подходит ли блок;
+// для остальных строк активного блока — с patterns == nil.
+func readSSHConfig(path, baseDir string, depth int, fn func(keyword string, args []string, patterns []string) bool) {
+	if depth > maxSSHConfigDepth {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	active := true
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keyword, args := parseSSHConfigLine(scanner.Text())
+		switch keyword {
+		case "":
+			continue
+		case "host":
+			active = fn(keyword, nil, args)
+		case "match":
+			active = len(args) == 1 && strings.EqualFold(args[0], "all")
+		case "include":
+			if !active {
+				continue
+			}
+			for _, pattern := range args {
+				pattern = expandHome(pattern)
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(baseDir, pattern)
+				}
+				matches, _ := filepath.Glob(pattern)
+				sort.Strings(matches)
+				for _, match := range matches {
+					readSSHConfig(match, baseDir, depth+1, fn)
+				}
+			}
+		default:
+			if active {
+				fn(keyword, args, nil)
+			}
+		}
+	}
+}
+
+// parseSSHConfigLine разбирает строку "Keyword value" или "Keyword=value";
+// значения в кавычках могут содержать пробелы
+func parseSSHConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		var arg string
+		if rest[0] == '"' {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				arg, rest = rest[1:], ""
+			} else {
+				arg, rest = rest[1:closing+1], rest[closing+2:]
+			}
+		} else if i := strings.IndexAny(rest, " \t"); i >= 0 {
+			arg, rest = rest[:i], rest[i:]
+		} else {
+			arg, rest = rest, ""
+		}
+		args = append(args, arg)
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return keyword, args
+}
+
+// matchHostPatterns проверяет алиас по шаблонам строки Host: подходит хотя бы
+// один шаблон и ни один отрицательный (!шаблон)
+func matchHostPatterns(alias string, patterns []string) bool {
+	alias = strings.ToLower(alias)
+	matched := false
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if negated := strings.HasPrefix(pattern, "!"); negated {
+			if wildcardMatch(pattern[1:], alias) {
+				return false
+			}
+			continue
+		}
+		if wildcardMatch(pattern, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// wildcardMatch сопоставляет строку с шаблоном, где * — любая
+// последовательность символов, а ? — один символ
+func wildcardMatch(pattern, s string) bool {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if wildcardMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+// expandSSHTokens раскрывает ~ и токены %% %d %u %n %h %r
+func expandSSHTokens(value, alias, host, remoteUser string) string {
+	if !strings.Contains(value, "%") {
+		return expandHome(value)
+	}
+	homeDir, _ := os.UserHomeDir()
+	localUser := localUserName()
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case '%':
+			sb.WriteByte('%')
+		case 'd':
+			sb.WriteString(homeDir)
+		case 'u':
+			sb.WriteString(localUser)
+		case 'n':
+			sb.WriteString(alias)
+		case 'h':
+			sb.WriteString(host)
+		case 'r':
+			sb.WriteString(remoteUser)
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(value[i])
+		}
+	}
+	return expandHome(sb.String())
+}
+
+// sshConfigAliases возвращает имена хостов из строк Host без шаблонов
+// для дополнения в запросе адреса
+func sshConfigAliases() []string {
+	seen := make(map[string]bool)
+	var aliases []string
+	for _, p := range sshConfigPaths() {
+		readSSHConfig(p[0], p[1], 0, func(keyword string, args []string, patterns []string) bool {
+			for _, pattern := range patterns {
+				if strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+					continue
+				}
+				seen[pattern] = true
+				aliases = append(aliases, pattern)
+			}
+			// Include внутри блоков Host тоже просматриваются
+			return true
+		})
+	}
+	sort.Strings(aliases)
+	return aliases
+}
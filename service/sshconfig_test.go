@@ -0,0 +1,150 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeSSHConfig записывает файлы конфигурации в ~/.ssh временной домашней
+// директории и возвращает пути для lookupSSHHostIn
+func writeSSHConfig(t *testing.T, files map[string]string) (string, [][2]string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sshDir := filepath.Join(home, ".ssh")
+	for name, content := range files {
+		path := filepath.Join(sshDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return home, [][2]string{{filepath.Join(sshDir, "config"), sshDir}}
+}
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := []struct {
+		alias    string
+		patterns []string
+		want     bool
+	}{
+		{"db", []string{"db"}, true},
+		{"DB", []string{"db"}, true},
+		{"db", []string{"web", "db"}, true},
+		{"db1", []string{"db"}, false},
+		{"db1", []string{"db?"}, true},
+		{"db12", []string{"db?"}, false},
+		{"db.example.com", []string{"*.example.com"}, true},
+		{"example.com", []string{"*.example.com"}, false},
+		{"anything", []string{"*"}, true},
+		{"bastion.example.com", []string{"*.example.com", "!bastion.example.com"}, false},
+		{"bastion.example.com", []string{"!bastion.*", "*"}, false},
+		{"db.example.com", []string{"!bastion.*", "*"}, true},
+		// Одни отрицательные шаблоны ничего не выбирают
+		{"db", []string{"!web"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchHostPatterns(tt.alias, tt.patterns); got != tt.want {
+			t.Errorf("%s %v: получено %v, ожидалось %v", tt.alias, tt.patterns, got, tt.want)
+		}
+	}
+}
+
+func TestParseSSHConfigLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		keyword string
+		args    []string
+	}{
+		{"  # комментарий", "", nil},
+		{"HostName db.internal", "hostname", []string{"db.internal"}},
+		{"Port=2222", "port", []string{"2222"}},
+		{"User = alice", "user", []string{"alice"}},
+		{"Host web db\t*.example.com", "host", []string{"web", "db", "*.example.com"}},
+		{`IdentityFile "~/my keys/id_ed25519"`, "identityfile", []string{"~/my keys/id_ed25519"}},
+	}
+	for _, tt := range tests {
+		keyword, args := parseSSHConfigLine(tt.line)
+		if keyword != tt.keyword || !slices.Equal(args, tt.args) {
+			t.Errorf("%q: получено %q %q", tt.line, keyword, args)
+		}
+	}
+}
+
+func TestLookupSSHHostFirstValueWins(t *testing.T) {
+	home, paths := writeSSHConfig(t, map[string]string{"config": `
+Host db
+    HostName db.internal
+    User alice
+    IdentityFile ~/.ssh/db_key
+
+Host !bastion *
+    User default
+    Port 2222
+    HostName ignored.example.com
+    IdentityFile ~/.ssh/%h_key
+    ProxyJump bastion
+
+Host bastion
+    User admin
+`})
+
+	config := lookupSSHHostIn(paths, "db")
+	if config.HostName != "db.internal" || config.User != "alice" || config.Port != "2222" || config.ProxyJump != "bastion" {
+		t.Fatalf("db: %+v", config)
+	}
+	want := []string{filepath.Join(home, ".ssh", "db_key"), filepath.Join(home, ".ssh", "db.internal_key")}
+	if !slices.Equal(config.IdentityFiles, want) {
+		t.Fatalf("IdentityFile накапливаются в порядке файла: %v, ожидалось %v", config.IdentityFiles, want)
+	}
+
+	// Отрицательный шаблон исключает bastion из общего блока
+	config = lookupSSHHostIn(paths, "bastion")
+	if config.HostName != "bastion" || config.User != "admin" || config.Port != "" || len(config.IdentityFiles) != 0 {
+		t.Fatalf("bastion: %+v", config)
+	}
+}
+
+func TestLookupSSHHostInclude(t *testing.T) {
+	_, paths := writeSSHConfig(t, map[string]string{
+		"config": `
+Include conf.d/*.conf
+Host other
+    Include other.conf
+Host *
+    User fallback
+`,
+		"conf.d/10-web.conf": `
+Host web
+    HostName web.internal
+    Include nested.conf
+`,
+		"conf.d/20-web.conf": `
+Host web
+    HostName second.internal
+`,
+		"nested.conf": `
+Port 2200
+Include nested.conf
+`,
+		"other.conf": `
+Host web
+    User from-other-block
+`,
+	})
+
+	config := lookupSSHHostIn(paths, "web")
+	// Файлы Include читаются по порядку имён, вложенные — относительно ~/.ssh,
+	// а самовключение обрывается на ограничении глубины
+	if config.HostName != "web.internal" || config.Port != "2200" {
+		t.Fatalf("web: %+v", config)
+	}
+	// Include внутри неподходящего блока Host пропускается
+	if config.User != "fallback" {
+		t.Fatalf("User из неподходящего блока: %q", config.User)
+	}
+}
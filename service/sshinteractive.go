@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+)
+
+// ===================== Интерактивный вход SSH =====================
+
+// sshDial — установка SSH-соединения в фоне. Сервер может задавать вопросы
+// (keyboard-interactive: пароль, одноразовый код): они приходят в интерфейс
+// сообщением sshPromptMsg, а ответы возвращаются через канал answers.
+type sshDial struct {
+	target  *remoteTarget
+	updates chan tea.Msg
+	answers chan []string
+}
+
+// sshPromptMsg содержит вопросы сервера для одного раунда keyboard-interactive
+type sshPromptMsg struct {
+	dial        *sshDial
+	host        string
+	instruction string
+	questions   []string
+	echos       []bool // false — ответ не отображается
+}
+
+// sshDialDoneMsg сообщает о завершении установки соединения
+type sshDialDoneMsg struct {
+	dial     *sshDial
+	clients  []*ssh.Client
+	rejected []string // ключи, для которых введена неверная парольная фраза
+	err      error
+}
+
+// sshPrompter задаёт пользователю вопросы сервера и возвращает ответы
+type sshPrompter func(host, instruction string, questions []string, echos []bool) ([]string, error)
+
+var errPromptCancelled = errors.New("ввод ответа отменён")
+
+// startSSHDial подключается к цепочке узлов в отдельной горутине
+func (m *FileManagerState) startSSHDial(target *remoteTarget, endpoints []*sshEndpoint) tea.Cmd {
+	d := &sshDial{
+		target:  target,
+		updates: make(chan tea.Msg, 1),
+		answers: make(chan []string, 1),
+	}
+	m.sshDial = d
+	keys := &sshKeys{passphrases: maps.Clone(m.keyPassphrases)}
+
+	go func() {
+		clients, err := dialSSHChain(endpoints, keys, d.ask)
+		d.updates <- sshDialDoneMsg{dial: d, clients: clients, rejected: keys.rejected, err: err}
+	}()
+
+	return d.wait()
+}
+
+// wait ожидает следующее сообщение от подключения
+func (d *sshDial) wait() tea.Cmd {
+	return func() tea.Msg {
+		return <-d.updates
+	}
+}
+
+// ask передаёт вопросы в интерфейс и ждёт ответов; закрытый канал ответов
+// означает, что пользователь отменил подключение
+func (d *sshDial) ask(host, instruction string, questions []string, echos []bool) ([]string, error) {
+	d.updates <- sshPromptMsg{dial: d, host: host, instruction: instruction, questions: questions, echos: echos}
+	answers, ok := <-d.answers
+	if !ok {
+		return nil, errPromptCancelled
+	}
+	return answers, nil
+}
+
+// keyboardChallenge отвечает на вопросы сервера keyboard-interactive.
+// Первый скрытый вопрос о пароле получает введённый пароль, остальные
+// (например, код TOTP) задаются пользователю.
+func keyboardChallenge(password string, prompt func(instruction string, questions []string, echos []bool) ([]string, error)) ssh.KeyboardInteractiveChallenge {
+	passwordUsed := false
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		var ask []int // вопросы, на которые отвечает пользователь
+		for i, question := range questions {
+			if password != "" && !passwordUsed && !echos[i] && isPasswordQuestion(question) {
+				passwordUsed = true
+				answers[i] = password
+				continue
+			}
+			ask = append(ask, i)
+		}
+		// Раунд без вопросов только сообщает instruction
+		if len(ask) == 0 {
+			return answers, nil
+		}
+		if prompt == nil {
+			return nil, fmt.Errorf("сервер запросил ввод: %s", strings.TrimSpace(questions[ask[0]]))
+		}
+
+		if name != "" {
+			instruction = strings.TrimSpace(name + ". " + instruction)
+		}
+		askQuestions := make([]string, len(ask))
+		askEchos := make([]bool, len(ask))
+		for j, i := range ask {
+			askQuestions[j], askEchos[j] = questions[i], echos[i]
+		}
+		replies, err := prompt(instruction, askQuestions, askEchos)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range ask {
+			answers[i] = replies[j]
+		}
+		return answers, nil
+	}
+}
+
+func isPasswordQuestion(question string) bool {
+	question = strings.ToLower(question)
+	return strings.Contains(question, "password") || strings.Contains(question, "пароль")
+}
+
+// showSSHPrompt переключает ввод на вопросы сервера
+func (m *FileManagerState) showSSHPrompt(msg sshPromptMsg) (tea.Model, tea.Cmd) {
+	// Вопросы отменённого подключения пропускаются
+	if msg.dial != m.sshDial {
+		return m, msg.dial.wait()
+	}
+	m.sshPrompt = &msg
+	m.sshAnswers = nil
+	m.mode = "ssh_prompt"
+	m.input = ""
+	m.status = strings.TrimSpace(msg.instruction)
+	return m, nil
+}
+
+// answerSSHPrompt принимает ответ на текущий вопрос; когда отвечены все
+// вопросы раунда, ответы передаются серверу
+func (m *FileManagerState) answerSSHPrompt() (tea.Model, tea.Cmd) {
+	m.sshAnswers = append(m.sshAnswers, m.input)
+	m.input = ""
+	if len(m.sshAnswers) < len(m.sshPrompt.questions) {
+		return m, nil
+	}
+
+	dial := m.sshPrompt.dial
+	dial.answers <- m.sshAnswers
+	m.sshPrompt = nil
+	m.sshAnswers = nil
+	m.mode = "connecting"
+	m.status = ""
+	return m, dial.wait()
+}
+
+// sshPromptText возвращает текущий вопрос сервера для верхней строки
+func (m *FileManagerState) sshPromptText() string {
+	question := strings.TrimSpace(m.sshPrompt.questions[len(m.sshAnswers)])
+	if question == "" {
+		question = "Ответ:"
+	}
+	return fmt.Sprintf("%s — %s ", m.sshPrompt.host, question)
+}
+
+// sshPromptEcho сообщает, можно ли показывать ответ на текущий вопрос
+func (m *FileManagerState) sshPromptEcho() bool {
+	return m.sshPrompt.echos[len(m.sshAnswers)]
+}
+
+// cancelSSHDial прерывает подключение, ожидающее сервера или ответа
+// пользователя; результат горутины дочитывается и отбрасывается
+func (m *FileManagerState) cancelSSHDial() tea.Cmd {
+	dial := m.sshDial
+	if dial == nil {
+		return nil
+	}
+	close(dial.answers)
+	m.sshDial = nil
+	m.sshPrompt = nil
+	m.sshAnswers = nil
+	m.status = "Подключение отменено"
+	return dial.wait()
+}
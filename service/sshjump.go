@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ===================== Переходы через промежуточные узлы =====================
+
+// Таймаут TCP-подключения к первому узлу цепочки
+const sshDialTimeout = 15 * time.Second
+
+// jumpEndpoints разбирает ProxyJump целевого узла в цепочку переходов.
+// Каждый переход может быть алиасом ~/.ssh/config со своими логином и
+// ключами; собственный ProxyJump первого перехода добавляется перед ним,
+// как это делает ssh.
+func jumpEndpoints(target *sshEndpoint) ([]*sshEndpoint, error) {
+	visited := map[string]bool{target.addr: true}
+	return expandJumps(target.proxyJump, visited, 0)
+}
+
+func expandJumps(spec string, visited map[string]bool, depth int) ([]*sshEndpoint, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.EqualFold(spec, "none") {
+		return nil, nil
+	}
+	if depth > maxSSHConfigDepth {
+		return nil, fmt.Errorf("слишком длинная цепочка ProxyJump")
+	}
+
+	var chain []*sshEndpoint
+	for i, hop := range strings.Split(spec, ",") {
+		endpoint, err := parseJumpHost(hop)
+		if err != nil {
+			return nil, err
+		}
+		if visited[endpoint.addr] {
+			return nil, fmt.Errorf("ProxyJump образует цикл через %s", endpoint.addr)
+		}
+		visited[endpoint.addr] = true
+
+		if i == 0 {
+			before, err := expandJumps(endpoint.proxyJump, visited, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, before...)
+		}
+		chain = append(chain, endpoint)
+	}
+	return chain, nil
+}
+
+// parseJumpHost разбирает переход вида [user@]host[:port] или
+// ssh://[user@]host[:port]. Пароль целевого сервера на переходах не
+// используется: вход выполняется ключами и через ssh-agent.
+func parseJumpHost(spec string) (*sshEndpoint, error) {
+	spec = strings.TrimPrefix(strings.TrimSpace(spec), "ssh://")
+	userName := ""
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		userName, spec = spec[:at], spec[at+1:]
+	}
+	target, err := parseRemoteTarget(spec)
+	if err != nil || target.scheme != "sftp" {
+		return nil, fmt.Errorf("неверный переход в ProxyJump: %q", spec)
+	}
+
+	hostConfig := lookupSSHHost(target.name)
+	_, port, _ := net.SplitHostPort(target.addr)
+	if !target.port && hostConfig.Port != "" {
+		port = hostConfig.Port
+	}
+	if userName == "" {
+		userName = hostConfig.User
+	}
+	if userName == "" {
+		userName = localUserName()
+	}
+
+	auth := &sshAuth{user: userName}
+	if config, err := loadSFTPConfig(); err == nil {
+		auth.identityFiles = config.IdentityFiles
+		auth.methods = config.AuthMethods
+	}
+	auth.identityFiles = append(auth.identityFiles, hostConfig.IdentityFiles...)
+
+	return &sshEndpoint{
+		addr:      net.JoinHostPort(hostConfig.HostName, port),
+		auth:      auth,
+		proxyJump: hostConfig.ProxyJump,
+	}, nil
+}
+
+// dialSSHChain подключается к узлам по очереди: каждый следующий — через
+// туннель предыдущего. Возвращает клиентов всех узлов, последний — целевой.
+// Вопросы keyboard-interactive любого узла передаются в prompt.
+func dialSSHChain(endpoints []*sshEndpoint, keys *sshKeys, prompt sshPrompter) ([]*ssh.Client, error) {
+	var clients []*ssh.Client
+	for i, endpoint := range endpoints {
+		var via *ssh.Client
+		if i > 0 {
+			via = clients[i-1]
+		}
+		client, err := dialSSHHop(via, endpoint, keys, prompt)
+		if err != nil {
+			closeSSHClients(clients)
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// dialSSHHop устанавливает SSH-соединение с узлом напрямую или через
+// уже открытое соединение с предыдущим переходом
+func dialSSHHop(via *ssh.Client, endpoint *sshEndpoint, keys *sshKeys, prompt sshPrompter) (*ssh.Client, error) {
+	var ask func(instruction string, questions []string, echos []bool) ([]string, error)
+	if prompt != nil {
+		ask = func(instruction string, questions []string, echos []bool) ([]string, error) {
+			return prompt(endpoint.addr, instruction, questions, echos)
+		}
+	}
+	authSession, err := keys.sshAuthMethods(endpoint.auth, ask)
+	if err != nil {
+		return nil, err
+	}
+	defer authSession.Close()
+
+	checkHostKey, err := hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:              endpoint.auth.user,
+		Auth:              authSession.methods,
+		HostKeyCallback:   checkHostKey,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(endpoint.addr),
+	}
+
+	var conn net.Conn
+	if via == nil {
+		conn, err = net.DialTimeout("tcp", endpoint.addr, sshDialTimeout)
+	} else {
+		conn, err = via.Dial("tcp", endpoint.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к %s: %v", endpoint.addr, err)
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, endpoint.addr, config)
+	if err != nil {
+		conn.Close()
+		var unknown *errUnknownHost
+		var mismatch *errHostKeyMismatch
+		switch {
+		case errors.As(err, &unknown):
+			return nil, unknown
+		case errors.As(err, &mismatch):
+			return nil, mismatch
+		}
+		if isAuthFailure(err) && len(authSession.locked) > 0 {
+			return nil, &errKeyPassphrase{path: authSession.locked[0]}
+		}
+		return nil, fmt.Errorf("не удалось подключиться к %s: %v", endpoint.addr, err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// closeSSHClients закрывает соединения цепочки, начиная с целевого узла
+func closeSSHClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
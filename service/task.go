@@ -0,0 +1,49 @@
+package service
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Фоновые задачи =====================
+
+// task — фоновая операция, которая сообщает о ходе выполнения через сообщения tea
+type task struct {
+	updates chan tea.Msg
+}
+
+// taskProgressMsg содержит промежуточный статус фоновой задачи
+type taskProgressMsg struct {
+	task   *task
+	status string
+}
+
+// taskDoneMsg сообщает о завершении фоновой задачи
+type taskDoneMsg struct {
+	status string
+	err    error
+}
+
+// startTask запускает run в отдельной горутине. Функция progress
+// не блокирует задачу: если интерфейс не успевает, промежуточный статус пропускается.
+func startTask(run func(progress func(string)) (string, error)) tea.Cmd {
+	t := &task{updates: make(chan tea.Msg, 1)}
+
+	go func() {
+		status, err := run(func(status string) {
+			select {
+			case t.updates <- taskProgressMsg{task: t, status: status}:
+			default:
+			}
+		})
+		t.updates <- taskDoneMsg{status: status, err: err}
+	}()
+
+	return t.wait()
+}
+
+// wait ожидает следующее сообщение задачи
+func (t *task) wait() tea.Cmd {
+	return func() tea.Msg {
+		return <-t.updates
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/KharpukhaevV/filemanager/models"
+	"github.com/KharpukhaevV/filemanager/utils"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Очередь передач =====================
+
+// transferJob — задача очереди. Задачи скачивания из архива читают открытый
+// в интерфейсе архив, поэтому не переживают перезапуск и не сохраняются.
+type transferJob struct {
+	models.TransferJob
+	fsys    vfs.FileSystem // архив, из которого идёт скачивание
+	release func()         // освобождает архив после завершения задачи
+	name    string         // файл, который передаётся сейчас
+}
+
+// transfer — выполняющаяся задача. Горутина передачи сообщает о ходе работы
+// сообщениями tea, поэтому навигация не блокируется. Одновременно
+// выполняется одна задача, остальные ждут своей очереди.
+type transfer struct {
+	job     *transferJob
+	started time.Time
+	resumed int64 // байт, переданных до запуска (докачка)
+	updates chan tea.Msg
+	cancel  chan struct{}
+	// stop — причина остановки: pause, cancel или disconnect. Записывается
+	// до закрытия cancel, поэтому горутина может читать её после отмены.
+	stop string
+}
+
+// transferProgressMsg содержит число переданных байт
+type transferProgressMsg struct {
+	transfer *transfer
+	name     string
+	done     int64
+	total    int64
+	resumed  int64
+}
+
+// transferSourceMsg передаёт размер и время изменения скачиваемого файла,
+// чтобы сохранить их в задаче до начала записи
+type transferSourceMsg struct {
+	transfer *transfer
+	size     int64
+	modTime  time.Time
+}
+
+// transferDoneMsg сообщает о завершении передачи
+type transferDoneMsg struct {
+	transfer *transfer
+	status   string
+	err      error
+}
+
+var errTransferCancelled = errors.New("передача отменена")
+
+// transferRun ведёт счёт переданных байт внутри горутины передачи
+type transferRun struct {
+	t         *transfer
+	total     int64
+	done      int64
+	resumed   int64
+	restarted int // файлы, которые не удалось докачать и пришлось передать заново
+}
+
+// setTotal задаёт объём передачи, когда он известен только при запуске
+func (r *transferRun) setTotal(total int64) {
+	r.total = total
+	r.report("")
+}
+
+// skip учитывает n байт, переданных при прошлом запуске: они входят
+// в процент выполнения, но не в скорость
+func (r *transferRun) skip(name string, n int64) {
+	r.done += n
+	r.resumed += n
+	r.report(name)
+}
+
+// setSource сохраняет в задаче сведения об источнике. В отличие от report
+// сообщение не пропускается: без него докачка после перезапуска невозможна.
+func (r *transferRun) setSource(info os.FileInfo) {
+	r.t.updates <- transferSourceMsg{transfer: r.t, size: info.Size(), modTime: info.ModTime()}
+}
+
+// restartNote сообщает в итоговом статусе, что сервер не поддерживает докачку
+func (r *transferRun) restartNote() string {
+	if r.restarted == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (сервер не поддерживает докачку, передано заново файлов: %d)", r.restarted)
+}
+
+// add учитывает n переданных байт; возвращает errTransferCancelled,
+// если передачу остановили
+func (r *transferRun) add(name string, n int64) error {
+	select {
+	case <-r.t.cancel:
+		return errTransferCancelled
+	default:
+	}
+	r.done += n
+	r.report(name)
+	return nil
+}
+
+// report отправляет счётчики в интерфейс; если интерфейс не успевает,
+// промежуточное значение пропускается
+func (r *transferRun) report(name string) {
+	select {
+	case r.t.updates <- transferProgressMsg{transfer: r.t, name: name, done: r.done, total: r.total, resumed: r.resumed}:
+	default:
+	}
+}
+
+// copy копирует src в dst блоками по 32 КБ; ошибки чтения и записи
+// подписываются именем файла
+func (r *transferRun) copy(dst io.Writer, src io.Reader, name string) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return fmt.Errorf("ошибка записи %s: %v", name, err)
+			}
+			if err := r.add(name, int64(n)); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка чтения %s: %v", name, err)
+		}
+	}
+}
+
+// cancelled сообщает, что передачу отменили насовсем и частичные файлы не нужны
+func (r *transferRun) cancelled(err error) bool {
+	return errors.Is(err, errTransferCancelled) && r.t.stop == "cancel"
+}
+
+// enqueue добавляет задачу в конец очереди и запускает очередь
+func (m *FileManagerState) enqueue(job *transferJob) tea.Cmd {
+	job.State = "pending"
+	m.queue = append(m.queue, job)
+	m.saveTransfers()
+	return m.runQueue()
+}
+
+// jobFS возвращает файловую систему задачи или nil, если нужного
+// подключения сейчас нет
+func (m *FileManagerState) jobFS(job *transferJob) vfs.FileSystem {
+	if job.fsys != nil {
+		return job.fsys
+	}
+	if m.isRemote && job.Host == m.remoteHost {
+		return m.fs
+	}
+	return nil
+}
+
+// runQueue запускает первую ожидающую задачу, для которой есть подключение
+func (m *FileManagerState) runQueue() tea.Cmd {
+	if m.active != nil {
+		return nil
+	}
+	for _, job := range m.queue {
+		if job.State != "pending" {
+			continue
+		}
+		if fsys := m.jobFS(job); fsys != nil {
+			return m.startTransfer(job, fsys)
+		}
+	}
+	return nil
+}
+
+// startTransfer выполняет задачу в отдельной горутине
+func (m *FileManagerState) startTransfer(job *transferJob, fsys vfs.FileSystem) tea.Cmd {
+	t := &transfer{
+		job:     job,
+		started: time.Now(),
+		updates: make(chan tea.Msg, 1),
+		cancel:  make(chan struct{}),
+	}
+	job.State = "active"
+	job.Error = ""
+	m.active = t
+	m.saveTransfers()
+
+	spec := job.TransferJob
+	go func() {
+		r := &transferRun{t: t, total: spec.Size}
+		var status string
+		var err error
+		if spec.Kind == "upload" {
+			status, err = runUpload(fsys, spec, r)
+		} else {
+			status, err = runDownload(fsys, spec, r)
+		}
+		t.updates <- transferDoneMsg{transfer: t, status: status, err: err}
+	}()
+
+	return t.wait()
+}
+
+// wait ожидает следующее сообщение передачи
+func (t *transfer) wait() tea.Cmd {
+	return func() tea.Msg {
+		return <-t.updates
+	}
+}
+
+// updateTransfer показывает ход передачи в строке статуса
+func (m *FileManagerState) updateTransfer(msg transferProgressMsg) (tea.Model, tea.Cmd) {
+	t := msg.transfer
+	if msg.name != "" {
+		t.job.name = msg.name
+	}
+	t.job.Done = msg.done
+	t.job.Size = msg.total
+	t.resumed = msg.resumed
+	m.status = m.transferStatus(t)
+	return m, t.wait()
+}
+
+// updateTransferSource запоминает источник скачивания и сразу сохраняет очередь
+func (m *FileManagerState) updateTransferSource(msg transferSourceMsg) (tea.Model, tea.Cmd) {
+	t := msg.transfer
+	t.job.SourceSize = msg.size
+	t.job.SourceModTime = msg.modTime
+	m.saveTransfers()
+	return m, t.wait()
+}
+
+// finishTransfer переводит задачу в итоговое состояние и запускает следующую
+func (m *FileManagerState) finishTransfer(msg transferDoneMsg) (tea.Model, tea.Cmd) {
+	t := msg.transfer
+	job := t.job
+	m.active = nil
+
+	switch {
+	case t.stop == "cancel":
+		m.removeJob(job)
+		m.status = "Передача отменена"
+	case t.stop == "pause":
+		job.State = "paused"
+		m.status = "Передача приостановлена"
+	case t.stop == "disconnect":
+		// Задача продолжится при следующем подключении к серверу
+		job.State = "pending"
+	case msg.err != nil:
+		job.State = "failed"
+		job.Error = msg.err.Error()
+		m.status = fmt.Sprintf("Ошибка: %v", msg.err)
+	default:
+		job.State = "done"
+		job.Done = job.Size
+		job.name = ""
+		m.releaseJob(job)
+		m.status = msg.status
+	}
+
+	m.saveTransfers()
+	m.refreshFiles()
+	return m, m.runQueue()
+}
+
+// stopTransfer останавливает выполняющуюся задачу; горутина завершается
+// на следующем блоке данных и присылает transferDoneMsg
+func (m *FileManagerState) stopTransfer(reason string) {
+	t := m.active
+	if t == nil || t.stop != "" {
+		return
+	}
+	t.stop = reason
+	close(t.cancel)
+}
+
+// removeJob убирает задачу из очереди. Недокачанный файл скачивания
+// удаляется; частичные файлы загрузки остаются на сервере.
+func (m *FileManagerState) removeJob(job *transferJob) {
+	m.queue = slices.DeleteFunc(m.queue, func(j *transferJob) bool { return j == job })
+	m.releaseJob(job)
+	if job.Kind == "download" && job.State != "done" {
+		os.Remove(job.Dest + ".part")
+	}
+}
+
+// releaseJob освобождает архив, из которого скачивала задача
+func (m *FileManagerState) releaseJob(job *transferJob) {
+	if job.release != nil {
+		job.release()
+		job.release = nil
+	}
+}
+
+// transferStatus формирует строку вида «Скачивание a.txt: 45% · 2.1 MB/s · осталось 0:12»
+func (m *FileManagerState) transferStatus(t *transfer) string {
+	job := t.job
+	parts := []string{fmt.Sprintf("%s %s: %d%%", jobLabel(job), job.name, percent(job.Done, job.Size))}
+	if speed, eta, ok := t.rate(); ok {
+		parts = append(parts, speed, "осталось "+eta)
+	}
+	status := strings.Join(parts, " · ")
+	if pending := m.pendingJobs(); pending > 0 {
+		status += fmt.Sprintf(" (в очереди: %d)", pending)
+	}
+	return status
+}
+
+// rate возвращает скорость передачи и оставшееся время; байты,
+// переданные до запуска, не учитываются
+func (t *transfer) rate() (speed, eta string, ok bool) {
+	elapsed := time.Since(t.started)
+	sent := t.job.Done - t.resumed
+	if elapsed < time.Second || sent <= 0 {
+		return "", "", false
+	}
+	bytesPerSecond := float64(sent) / elapsed.Seconds()
+	left := max(t.job.Size-t.job.Done, 0)
+	return utils.FormatSize(int64(bytesPerSecond)) + "/s",
+		formatETA(time.Duration(float64(left) / bytesPerSecond * float64(time.Second))), true
+}
+
+func (m *FileManagerState) pendingJobs() int {
+	count := 0
+	for _, job := range m.queue {
+		if job.State == "pending" {
+			count++
+		}
+	}
+	return count
+}
+
+func jobLabel(job *transferJob) string {
+	if job.Kind == "upload" {
+		return "Загрузка"
+	}
+	return "Скачивание"
+}
+
+// formatETA форматирует оставшееся время как м:сс или ч:мм:сс
+func formatETA(d time.Duration) string {
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// ===================== Сохранение очереди =====================
+
+// loadTransfers читает очередь, сохранённую при прошлом запуске. Задачи,
+// прерванные выходом из программы, снова ждут своей очереди.
+func loadTransfers() []*transferJob {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(homeDir, models.TransfersFile))
+	if err != nil {
+		return nil
+	}
+	var saved []models.TransferJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil
+	}
+
+	queue := make([]*transferJob, 0, len(saved))
+	for _, job := range saved {
+		if job.State == "active" {
+			job.State = "pending"
+		}
+		queue = append(queue, &transferJob{TransferJob: job})
+	}
+	return queue
+}
+
+// saveTransfers сохраняет очередь в ~/.filemanager/transfers.json
+func (m *FileManagerState) saveTransfers() {
+	saved := make([]models.TransferJob, 0, len(m.queue))
+	for _, job := range m.queue {
+		if job.fsys == nil {
+			saved = append(saved, job.TransferJob)
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return
+	}
+	path := filepath.Join(homeDir, models.TransfersFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		m.status = fmt.Sprintf("Не удалось сохранить очередь передач: %v", err)
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		m.status = fmt.Sprintf("Не удалось сохранить очередь передач: %v", err)
+	}
+}
+
+// ===================== Скачивание =====================
+
+// runDownload скачивает файл во временный Dest.part и переименовывает его
+// после успешной записи. Если недокачанный файл остался от прошлого запуска,
+// а размер и время изменения источника совпадают с сохранёнными в задаче,
+// скачивание продолжается с его конца (см. vfs.OpenFrom); иначе файл
+// скачивается заново. Если сервер не умеет отдавать файл с середины, файл
+// тоже скачивается заново и это отмечается в итоговом статусе.
+func runDownload(fsys vfs.FileSystem, job models.TransferJob, r *transferRun) (string, error) {
+	name := filepath.Base(job.Source)
+	info, err := fsys.Stat(job.Source)
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия файла на сервере: %v", err)
+	}
+	size := info.Size()
+	r.setTotal(size)
+
+	if err := os.MkdirAll(filepath.Dir(job.Dest), 0755); err != nil {
+		return "", fmt.Errorf("ошибка создания директории для скачивания: %v", err)
+	}
+
+	partPath := job.Dest + ".part"
+	var offset int64
+	if part, err := os.Stat(partPath); err == nil && sameSource(job, info) && part.Size() <= size {
+		offset = part.Size()
+	}
+	r.setSource(info)
+
+	src, err := vfs.OpenFrom(fsys, job.Source, offset)
+	if errors.Is(err, vfs.ErrResumeUnsupported) {
+		offset = 0
+		r.restarted++
+		src, err = fsys.Open(job.Source)
+	}
+	if err != nil {
+		return "", fmt.Errorf("ошибка открытия файла на сервере: %v", err)
+	}
+	defer src.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+		r.skip(name, offset)
+	}
+	dst, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания локального файла: %v", err)
+	}
+
+	err = r.copy(dst, src, name)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Недокачанный файл остаётся для докачки, если передачу не отменили
+		if r.cancelled(err) {
+			os.Remove(partPath)
+		}
+		return "", err
+	}
+
+	if err := os.Rename(partPath, job.Dest); err != nil {
+		return "", fmt.Errorf("ошибка сохранения %s: %v", job.Dest, err)
+	}
+	return fmt.Sprintf("Файл %s скачан в %s", name, filepath.Dir(job.Dest)) + r.restartNote(), nil
+}
+
+// sameSource проверяет, что файл на сервере не изменился с запуска задачи.
+// Задачи без сохранённых сведений (от прежних версий) докачке не доверяют.
+func sameSource(job models.TransferJob, info os.FileInfo) bool {
+	if job.SourceSize == 0 && job.SourceModTime.IsZero() {
+		return false
+	}
+	return job.SourceSize == info.Size() && job.SourceModTime.Equal(info.ModTime())
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KharpukhaevV/filemanager/models"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRunDownloadResume(t *testing.T) {
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		part   string
+		source func(job *models.TransferJob) // сведения об источнике, сохранённые в задаче
+		want   string
+	}{
+		{
+			name:   "источник не изменился",
+			part:   "ABCD",
+			source: func(job *models.TransferJob) { job.SourceSize, job.SourceModTime = 10, modTime },
+			want:   "ABCD456789",
+		},
+		{
+			name:   "изменилось время",
+			part:   "ABCD",
+			source: func(job *models.TransferJob) { job.SourceSize, job.SourceModTime = 10, modTime.Add(time.Second) },
+			want:   "0123456789",
+		},
+		{
+			name:   "изменился размер",
+			part:   "ABCD",
+			source: func(job *models.TransferJob) { job.SourceSize, job.SourceModTime = 12, modTime },
+			want:   "0123456789",
+		},
+		{
+			name:   "часть больше источника",
+			part:   "ABCDEFGHIJKL",
+			source: func(job *models.TransferJob) { job.SourceSize, job.SourceModTime = 10, modTime },
+			want:   "0123456789",
+		},
+		{
+			name:   "задача без сведений об источнике",
+			part:   "ABCD",
+			source: func(job *models.TransferJob) {},
+			want:   "0123456789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "remote.bin")
+			if err := os.WriteFile(source, []byte("0123456789"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(source, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			dest := filepath.Join(dir, "local", "remote.bin")
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(dest+".part", []byte(tt.part), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			job := models.TransferJob{Kind: "download", Source: source, Dest: dest}
+			tt.source(&job)
+			tr := &transfer{updates: make(chan tea.Msg, 64), cancel: make(chan struct{})}
+			if _, err := runDownload(vfs.NewLocalFS(), job, &transferRun{t: tr}); err != nil {
+				t.Fatalf("runDownload: %v", err)
+			}
+
+			if data, err := os.ReadFile(dest); err != nil || string(data) != tt.want {
+				t.Fatalf("скачано %q, %v; ожидалось %q", data, err, tt.want)
+			}
+			if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+				t.Fatalf("временный файл остался: %v", err)
+			}
+
+			// Сведения об источнике передаются интерфейсу для сохранения в очереди
+			close(tr.updates)
+			var got *transferSourceMsg
+			for msg := range tr.updates {
+				if source, ok := msg.(transferSourceMsg); ok {
+					got = &source
+				}
+			}
+			if got == nil || got.size != 10 || !got.modTime.Equal(modTime) {
+				t.Fatalf("сведения об источнике: %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/KharpukhaevV/filemanager/models"
+	"github.com/KharpukhaevV/filemanager/vfs"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ===================== Загрузка на сервер =====================
+
+// uploadItem — один файл или директория загрузки
+type uploadItem struct {
+	src  string
+	dest string
+	info os.FileInfo
+	skip bool // файл уже есть на сервере и не загружается
+}
+
+// startUpload запрашивает локальный путь для загрузки в текущую директорию сервера
+func (m *FileManagerState) startUpload() (tea.Model, tea.Cmd) {
+	if !m.isRemote {
+		return m, tea.Println("Нет подключения к серверу")
+	}
+	if m.inArchive() {
+		m.status = "Загрузка внутрь архива не поддерживается"
+		return m, nil
+	}
+	m.mode = "upload"
+	m.input = m.localDir() + string(os.PathSeparator)
+	return m, nil
+}
+
+// completeLocalPath дополняет введённый локальный путь по Tab
+func (m *FileManagerState) completeLocalPath() {
+	input := expandHome(m.input)
+	dir, prefix := filepath.Split(input)
+	lookup := dir
+	if !filepath.IsAbs(lookup) {
+		lookup = filepath.Join(m.localDir(), lookup)
+	}
+	entries, err := os.ReadDir(lookup)
+	if err != nil {
+		m.status = fmt.Sprintf("Ошибка чтения %s: %v", lookup, err)
+		return
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		name := entry.Name()
+		if info, err := os.Stat(filepath.Join(lookup, name)); err == nil && info.IsDir() {
+			name += string(os.PathSeparator)
+		}
+		matches = append(matches, name)
+	}
+
+	switch len(matches) {
+	case 0:
+		m.status = "Нет подходящих файлов"
+	case 1:
+		m.input = dir + matches[0]
+		m.status = ""
+	default:
+		m.input = dir + commonPrefix(matches)
+		m.status = "Варианты: " + strings.Join(matches, ", ")
+	}
+}
+
+// commonPrefix возвращает общее начало строк
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// cancelUpload отменяет подготовленную загрузку
+func (m *FileManagerState) cancelUpload() {
+	m.pendingUpload = nil
+}
+
+// planUpload проверяет загрузку source в текущую директорию сервера
+// и ставит её в очередь передач
+func (m *FileManagerState) planUpload(source string) (tea.Model, tea.Cmd) {
+	source = expandHome(strings.TrimSpace(source))
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(m.localDir(), source)
+	}
+	job := &transferJob{TransferJob: models.TransferJob{
+		Kind:   "upload",
+		Host:   m.remoteHost,
+		Source: filepath.Clean(source),
+		Dest:   m.Cwd,
+	}}
+
+	items, err := uploadItems(job.Source, job.Dest)
+	if err != nil {
+		m.mode = "normal"
+		m.input = ""
+		return m, tea.Println("Ошибка загрузки:", err)
+	}
+
+	// Содержимое директорий сервера читается один раз для поиска конфликтов
+	existing := make(map[string]map[string]bool)
+	exists := func(dest string) bool {
+		dir := filepath.Dir(dest)
+		names, ok := existing[dir]
+		if !ok {
+			names = make(map[string]bool)
+			if infos, err := m.fs.ReadDir(dir); err == nil {
+				for _, info := range infos {
+					names[info.Name()] = true
+				}
+			}
+			existing[dir] = names
+		}
+		return names[filepath.Base(dest)]
+	}
+
+	conflicts := 0
+	for _, item := range items {
+		if item.info.Mode().IsRegular() {
+			job.Size += item.info.Size()
+			if exists(item.dest) {
+				conflicts++
+			}
+		}
+	}
+
+	m.pendingUpload = job
+	if conflicts > 0 {
+		m.mode = "upload_overwrite"
+		m.input = ""
+		m.status = fmt.Sprintf("Уже существует файлов на сервере: %d", conflicts)
+		return m, nil
+	}
+	return m.runUpload()
+}
+
+// runUpload ставит подготовленную загрузку в очередь передач
+func (m *FileManagerState) runUpload() (tea.Model, tea.Cmd) {
+	job := m.pendingUpload
+	m.pendingUpload = nil
+	m.mode = "normal"
+	m.input = ""
+	return m, m.enqueue(job)
+}
+
+// uploadItems обходит локальное дерево source и сопоставляет каждому
+// элементу путь в директории target на сервере
+func uploadItems(source, target string) ([]uploadItem, error) {
+	var items []uploadItem
+	parent := filepath.Dir(source)
+	err := vfs.Walk(vfs.NewLocalFS(), source, func(name string, info os.FileInfo) error {
+		rel, err := filepath.Rel(parent, name)
+		if err != nil {
+			return err
+		}
+		// Символическая ссылка на файл загружается как обычный файл
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(name); err == nil && target.Mode().IsRegular() {
+				info = target
+			}
+		}
+		items = append(items, uploadItem{src: name, dest: filepath.Join(target, rel), info: info})
+		return nil
+	})
+	return items, err
+}
+
+// runUpload загружает файлы задачи. Дерево обходится заново при каждом
+// запуске: файлы, которые уже загружены (совпадают размер и время изменения),
+// пропускаются, а недописанный .part продолжается с места остановки.
+func runUpload(fsys vfs.FileSystem, job models.TransferJob, r *transferRun) (string, error) {
+	items, err := uploadItems(job.Source, job.Dest)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения %s: %v", job.Source, err)
+	}
+
+	var total int64
+	for i, item := range items {
+		if !item.info.Mode().IsRegular() {
+			continue
+		}
+		if existing, err := fsys.Stat(item.dest); err == nil {
+			items[i].skip = existing.IsDir() || !job.Overwrite || sameFile(existing, item.info)
+		}
+		if !items[i].skip {
+			total += item.info.Size()
+		}
+	}
+	r.setTotal(total)
+
+	uploaded, skipped := 0, 0
+	var dirs []uploadItem
+	for _, item := range items {
+		mode := item.info.Mode()
+		switch {
+		case mode.IsDir():
+			if err := uploadMkdir(fsys, item.dest); err != nil {
+				return "", fmt.Errorf("ошибка создания директории %s: %v", item.dest, err)
+			}
+			dirs = append(dirs, item)
+			continue
+		case !mode.IsRegular(), item.skip:
+			skipped++
+			continue
+		}
+
+		if err := uploadFile(fsys, item, r); err != nil {
+			return "", err
+		}
+		uploaded++
+	}
+
+	// Права и время директорий выставляются в конце, от вложенных к внешним
+	sort.Slice(dirs, func(i, j int) bool { return len(dirs[i].dest) > len(dirs[j].dest) })
+	for _, dir := range dirs {
+		fsys.Chmod(dir.dest, dirPerm(dir.info.Mode()))
+		vfs.Chtimes(fsys, dir.dest, dir.info.ModTime(), dir.info.ModTime())
+	}
+
+	status := fmt.Sprintf("Загружено файлов: %d в %s", uploaded, job.Dest)
+	if skipped > 0 {
+		status += fmt.Sprintf(", пропущено: %d", skipped)
+	}
+	return status + r.restartNote(), nil
+}
+
+// sameFile сравнивает файл на сервере с локальным по размеру и времени изменения
+func sameFile(remote, local os.FileInfo) bool {
+	return remote.Size() == local.Size() && remote.ModTime().Unix() == local.ModTime().Unix()
+}
+
+// uploadMkdir создаёт директорию на сервере, если её ещё нет
+func uploadMkdir(fsys vfs.FileSystem, dir string) error {
+	if info, err := fsys.Stat(dir); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("на сервере есть файл с таким именем")
+		}
+		return nil
+	}
+	return fsys.Mkdir(dir)
+}
+
+// uploadFile копирует один файл на сервер, сохраняя права и время изменения.
+// Файл пишется во временный и переименовывается только после успешной записи.
+func uploadFile(fsys vfs.FileSystem, item uploadItem, r *transferRun) error {
+	name := filepath.Base(item.src)
+	src, err := os.Open(item.src)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия %s: %v", item.src, err)
+	}
+	defer src.Close()
+
+	tmpPath := item.dest + ".part"
+	dst, offset, err := resumeUpload(fsys, tmpPath, item.info.Size())
+	if errors.Is(err, vfs.ErrAppendUnsupported) {
+		r.restarted++
+	}
+	if offset > 0 {
+		if _, err := src.Seek(offset, io.SeekStart); err != nil {
+			dst.Close()
+			dst, offset = nil, 0
+		}
+	}
+	if dst == nil {
+		if dst, err = fsys.Create(tmpPath); err != nil {
+			return fmt.Errorf("ошибка создания файла на сервере: %v", err)
+		}
+	}
+	r.skip(name, offset)
+
+	if err := r.copy(dst, src, name); err != nil {
+		dst.Close()
+		// Частичный файл остаётся для докачки, если передачу не отменили
+		if r.cancelled(err) {
+			fsys.Remove(tmpPath)
+		}
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("ошибка записи на сервер: %v", err)
+	}
+
+	if err := vfs.Replace(fsys, tmpPath, item.dest); err != nil {
+		fsys.Remove(tmpPath)
+		return fmt.Errorf("ошибка сохранения %s: %v", item.dest, err)
+	}
+
+	fsys.Chmod(item.dest, item.info.Mode().Perm())
+	vfs.Chtimes(fsys, item.dest, item.info.ModTime(), item.info.ModTime())
+	return nil
+}
+
+// resumeUpload открывает для дозаписи частичный файл прошлой загрузки.
+// Возвращает nil, если продолжать нечего или сервер не умеет дописывать
+// файлы; во втором случае ошибка — vfs.ErrAppendUnsupported.
+func resumeUpload(fsys vfs.FileSystem, tmpPath string, size int64) (vfs.File, int64, error) {
+	info, err := fsys.Stat(tmpPath)
+	if err != nil || info.Size() == 0 || info.Size() > size {
+		return nil, 0, nil
+	}
+	dst, err := vfs.OpenAppend(fsys, tmpPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	return dst, info.Size(), nil
+}
@@ -0,0 +1,477 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// ===================== Хранилище паролей =====================
+
+// Параметры Argon2id для новых хранилищ; в файле хранятся фактические
+const (
+	vaultKDF     = "argon2id"
+	vaultTime    = 3
+	vaultMemory  = 64 * 1024 // КиБ
+	vaultThreads = 4
+	vaultSaltLen = 16
+
+	// Предел памяти Argon2id из файла: повреждённый или подменённый файл не
+	// должен заставлять выделять гигабайты
+	vaultMaxMemory = 1024 * 1024 // КиБ
+)
+
+var errVaultPassphrase = errors.New("неверный мастер-пароль")
+
+// vaultFile — содержимое ~/.filemanager/vault.json. Секреты зашифрованы
+// XChaCha20-Poly1305 ключом, полученным из мастер-пароля через Argon2id;
+// параметры KDF и имена записей открыты, но защищены от подмены как
+// дополнительные данные AEAD.
+type vaultFile struct {
+	KDF     string   `json:"kdf"`
+	Time    uint32   `json:"time"`
+	Memory  uint32   `json:"memory"`
+	Threads uint8    `json:"threads"`
+	Salt    []byte   `json:"salt"`
+	Entries []string `json:"entries"`
+	Nonce   []byte   `json:"nonce,omitempty"`
+	Data    []byte   `json:"data,omitempty"`
+}
+
+// additionalData возвращает открытую часть файла, которую подтверждает AEAD
+func (f *vaultFile) additionalData() []byte {
+	header := *f
+	header.Nonce, header.Data = nil, nil
+	data, _ := json.Marshal(header)
+	return data
+}
+
+// credentialVault — открытое хранилище: ключ шифрования и расшифрованные секреты
+type credentialVault struct {
+	file    vaultFile
+	key     []byte
+	secrets map[string]string
+}
+
+// vaultRequest — действие, отложенное до ввода мастер-пароля, и режим,
+// в который нужно вернуться
+type vaultRequest struct {
+	next  func() (tea.Model, tea.Cmd)
+	mode  string
+	input string
+}
+
+// Имена секретов в хранилище
+func profileSecret(name string) string { return "sftp/" + name }
+func s3Secret(name string) string      { return "s3/" + name }
+
+func vaultPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, models.VaultFile), nil
+}
+
+// loadVaultFile читает хранилище; если его ещё нет, возвращает nil
+func loadVaultFile() (*vaultFile, error) {
+	path, err := vaultPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("повреждён файл хранилища: %v", err)
+	}
+	return &file, nil
+}
+
+// vaultHasSecret проверяет по открытому списку записей, сохранён ли секрет;
+// мастер-пароль для этого не нужен
+func vaultHasSecret(name string) bool {
+	file, err := loadVaultFile()
+	return err == nil && file != nil && slices.Contains(file.Entries, name)
+}
+
+func (f *vaultFile) deriveKey(passphrase string) ([]byte, error) {
+	if f.KDF != vaultKDF {
+		return nil, fmt.Errorf("неподдерживаемый KDF хранилища: %s", f.KDF)
+	}
+	// argon2 паникует при нулевом числе проходов или потоков
+	if f.Time == 0 || f.Threads == 0 || f.Memory > vaultMaxMemory {
+		return nil, fmt.Errorf("повреждён файл хранилища: недопустимые параметры KDF")
+	}
+	return argon2.IDKey([]byte(passphrase), f.Salt, f.Time, f.Memory, f.Threads, chacha20poly1305.KeySize), nil
+}
+
+// openVault расшифровывает хранилище мастер-паролем
+func openVault(file *vaultFile, passphrase string) (*credentialVault, error) {
+	key, err := file.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("повреждён файл хранилища")
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, file.additionalData())
+	if err != nil {
+		return nil, errVaultPassphrase
+	}
+
+	vault := &credentialVault{file: *file, key: key}
+	if err := json.Unmarshal(plain, &vault.secrets); err != nil {
+		return nil, fmt.Errorf("повреждён файл хранилища: %v", err)
+	}
+	if vault.secrets == nil {
+		vault.secrets = make(map[string]string)
+	}
+	return vault, nil
+}
+
+// newVault создаёт пустое хранилище со случайной солью; на диск оно
+// записывается при первом сохранении секрета
+func newVault(passphrase string) (*credentialVault, error) {
+	file := vaultFile{
+		KDF:     vaultKDF,
+		Time:    vaultTime,
+		Memory:  vaultMemory,
+		Threads: vaultThreads,
+		Salt:    make([]byte, vaultSaltLen),
+	}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return nil, err
+	}
+	key, err := file.deriveKey(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &credentialVault{file: file, key: key, secrets: make(map[string]string)}, nil
+}
+
+// save шифрует секреты с новым nonce и записывает хранилище
+func (v *credentialVault) save() error {
+	aead, err := chacha20poly1305.NewX(v.key)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+
+	file := v.file
+	file.Entries = make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		file.Entries = append(file.Entries, name)
+	}
+	sort.Strings(file.Entries)
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plain, file.additionalData())
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	path, err := vaultPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	v.file = file
+	return nil
+}
+
+// ===================== Использование хранилища =====================
+
+// credentialsDisabled сообщает, что пользователь отказался сохранять пароли
+func credentialsDisabled() bool {
+	config, err := loadSFTPConfig()
+	return err == nil && config.Credentials == "none"
+}
+
+// disableCredentials отключает сохранение паролей в sftp_config.json
+func disableCredentials() error {
+	config, err := loadSFTPConfig()
+	if err != nil {
+		config = &models.SFTPConfig{}
+	}
+	config.Credentials = "none"
+	return saveSFTPConfig(config)
+}
+
+// withVault выполняет next, когда хранилище открыто. Если оно закрыто,
+// сначала запрашивается мастер-пароль (или создаётся новое хранилище);
+// при пропуске запроса next вызывается с закрытым хранилищем (m.vault == nil).
+func (m *FileManagerState) withVault(next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if m.vault != nil || credentialsDisabled() {
+		return next()
+	}
+	file, err := loadVaultFile()
+	if err != nil {
+		m.status = fmt.Sprintf("Ошибка чтения хранилища: %v", err)
+		return next()
+	}
+
+	m.pendingVault = &vaultRequest{next: next, mode: m.mode, input: m.input}
+	m.input = ""
+	if file == nil {
+		m.mode = "vault_create"
+	} else {
+		m.mode = "vault_unlock"
+	}
+	return m, nil
+}
+
+// resumeVault возвращается в режим, из которого запрошен мастер-пароль,
+// и продолжает отложенное действие
+func (m *FileManagerState) resumeVault() (tea.Model, tea.Cmd) {
+	request := m.pendingVault
+	m.pendingVault = nil
+	m.vaultPassphrase = ""
+	if request == nil {
+		m.mode = "normal"
+		m.input = ""
+		return m, nil
+	}
+	m.mode = request.mode
+	m.input = request.input
+	return request.next()
+}
+
+// withSecret передаёт в next сохранённый секрет; если он есть в хранилище,
+// сначала запрашивается мастер-пароль
+func (m *FileManagerState) withSecret(name string, next func(secret string) (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if !vaultHasSecret(name) {
+		return next("")
+	}
+	return m.withVault(func() (tea.Model, tea.Cmd) {
+		if m.vault == nil {
+			return next("")
+		}
+		return next(m.vault.secrets[name])
+	})
+}
+
+// changeSecrets изменяет секреты и сохраняет хранилище. Записи профилей,
+// которых больше нет, при этом удаляются.
+func (m *FileManagerState) changeSecrets(change func(secrets map[string]string)) (tea.Model, tea.Cmd) {
+	if credentialsDisabled() {
+		return m, nil
+	}
+	return m.withVault(func() (tea.Model, tea.Cmd) {
+		if m.vault == nil {
+			return m, nil
+		}
+		change(m.vault.secrets)
+		pruneSecrets(m.vault.secrets)
+		if err := m.vault.save(); err != nil {
+			m.status = fmt.Sprintf("Не удалось сохранить хранилище: %v", err)
+		}
+		return m, nil
+	})
+}
+
+// storeSecret сохраняет пароль, введённый при подключении, если он ещё не сохранён
+func (m *FileManagerState) storeSecret(name, secret string) (tea.Model, tea.Cmd) {
+	if secret == "" || (m.vault != nil && m.vault.secrets[name] == secret) {
+		return m, nil
+	}
+	return m.changeSecrets(func(secrets map[string]string) {
+		secrets[name] = secret
+	})
+}
+
+// pruneSecrets удаляет секреты удалённых профилей
+func pruneSecrets(secrets map[string]string) {
+	known := make(map[string]bool)
+	profiles, err := loadSFTPProfiles()
+	if err != nil {
+		return
+	}
+	for _, profile := range profiles {
+		known[profileSecret(profile.Name)] = true
+	}
+	s3Profiles, err := loadS3Profiles()
+	if err != nil {
+		return
+	}
+	for _, profile := range s3Profiles {
+		known[s3Secret(profile.Name)] = true
+	}
+	for name := range secrets {
+		if !known[name] {
+			delete(secrets, name)
+		}
+	}
+}
+
+// migratePlaintextSecrets переносит пароли, сохранённые прежними версиями
+// открытым текстом, в хранилище и убирает их из профилей. Если сохранение
+// паролей отключено, пароли просто удаляются.
+func (m *FileManagerState) migratePlaintextSecrets(next func() (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	profiles, err := loadSFTPProfiles()
+	if err != nil {
+		return next()
+	}
+	s3Profiles, err := loadS3Profiles()
+	if err != nil {
+		return next()
+	}
+
+	plain := make(map[string]string)
+	for _, profile := range profiles {
+		if profile.Password != "" {
+			plain[profileSecret(profile.Name)] = profile.Password
+		}
+	}
+	for _, profile := range s3Profiles {
+		if profile.SecretKey != "" {
+			plain[s3Secret(profile.Name)] = profile.SecretKey
+		}
+	}
+	if len(plain) == 0 {
+		return next()
+	}
+
+	strip := func() error {
+		for i := range profiles {
+			profiles[i].Password = ""
+		}
+		for i := range s3Profiles {
+			s3Profiles[i].SecretKey = ""
+		}
+		if err := saveSFTPProfiles(profiles); err != nil {
+			return err
+		}
+		return saveS3Profiles(s3Profiles)
+	}
+	finish := func(status string) (tea.Model, tea.Cmd) {
+		model, cmd := next()
+		m.status = status
+		return model, cmd
+	}
+
+	if credentialsDisabled() {
+		if err := strip(); err != nil {
+			return finish(fmt.Sprintf("Не удалось удалить пароли из профилей: %v", err))
+		}
+		return finish("Пароли удалены из профилей: сохранение паролей отключено")
+	}
+
+	m.status = "Пароли профилей хранятся открытым текстом и будут перенесены в хранилище"
+	return m.withVault(func() (tea.Model, tea.Cmd) {
+		if credentialsDisabled() {
+			return m.migratePlaintextSecrets(next)
+		}
+		if m.vault == nil {
+			return finish("Пароли профилей по-прежнему хранятся открытым текстом")
+		}
+		for name, secret := range plain {
+			m.vault.secrets[name] = secret
+		}
+		if err := m.vault.save(); err != nil {
+			return finish(fmt.Sprintf("Не удалось сохранить хранилище: %v", err))
+		}
+		if err := strip(); err != nil {
+			return finish(fmt.Sprintf("Не удалось удалить пароли из профилей: %v", err))
+		}
+		return finish("Пароли профилей перенесены в зашифрованное хранилище")
+	})
+}
+
+// handleVaultInput обрабатывает ввод мастер-пароля
+func (m *FileManagerState) handleVaultInput() (tea.Model, tea.Cmd) {
+	switch m.mode {
+	case "vault_unlock":
+		// Пустой ввод пропускает хранилище
+		if m.input == "" {
+			return m.resumeVault()
+		}
+		file, err := loadVaultFile()
+		if err == nil && file == nil {
+			err = fmt.Errorf("файл хранилища не найден")
+		}
+		var vault *credentialVault
+		if err == nil {
+			vault, err = openVault(file, m.input)
+		}
+		m.input = ""
+		if err != nil {
+			m.status = err.Error()
+			return m, nil
+		}
+		m.status = ""
+		m.vault = vault
+		return m.resumeVault()
+
+	case "vault_create":
+		if m.input == "" {
+			m.mode = "vault_optout"
+			return m, nil
+		}
+		m.vaultPassphrase = m.input
+		m.input = ""
+		m.mode = "vault_confirm"
+
+	case "vault_confirm":
+		if m.input != m.vaultPassphrase {
+			m.vaultPassphrase = ""
+			m.input = ""
+			m.status = "Мастер-пароли не совпадают"
+			m.mode = "vault_create"
+			return m, nil
+		}
+		vault, err := newVault(m.input)
+		if err != nil {
+			m.status = fmt.Sprintf("Не удалось создать хранилище: %v", err)
+			return m.resumeVault()
+		}
+		m.status = ""
+		m.vault = vault
+		return m.resumeVault()
+
+	case "vault_optout":
+		if m.input != "y" && m.input != "n" {
+			return m, nil
+		}
+		if m.input == "y" {
+			if err := disableCredentials(); err != nil {
+				m.status = fmt.Sprintf("Не удалось сохранить настройку: %v", err)
+			} else {
+				m.status = "Пароли больше не сохраняются"
+			}
+		}
+		return m.resumeVault()
+	}
+	return m, nil
+}
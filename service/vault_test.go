@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// cheapVault создаёт хранилище с дешёвыми параметрами Argon2id, чтобы тесты не
+// тратили на вывод ключа по 64 МиБ памяти
+func cheapVault(t *testing.T, passphrase string) *credentialVault {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	file := vaultFile{KDF: vaultKDF, Time: 1, Memory: 64, Threads: 1, Salt: []byte("0123456789abcdef")}
+	key, err := file.deriveKey(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &credentialVault{file: file, key: key, secrets: make(map[string]string)}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	vault := cheapVault(t, "мастер-пароль")
+	vault.secrets[profileSecret("prod")] = "ssh-пароль"
+	vault.secrets[s3Secret("backup")] = "secret key"
+	if err := vault.save(); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	path, err := vaultPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("права файла хранилища: %v", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret key") {
+		t.Fatal("секрет записан открытым текстом")
+	}
+
+	file, err := loadVaultFile()
+	if err != nil || file == nil {
+		t.Fatalf("loadVaultFile: %v", err)
+	}
+	if want := []string{"s3/backup", "sftp/prod"}; !slices.Equal(file.Entries, want) {
+		t.Fatalf("открытый список записей: %v", file.Entries)
+	}
+	if !vaultHasSecret(profileSecret("prod")) || vaultHasSecret(profileSecret("missing")) {
+		t.Fatal("vaultHasSecret не совпадает со списком записей")
+	}
+
+	opened, err := openVault(file, "мастер-пароль")
+	if err != nil {
+		t.Fatalf("openVault: %v", err)
+	}
+	if opened.secrets[profileSecret("prod")] != "ssh-пароль" || opened.secrets[s3Secret("backup")] != "secret key" {
+		t.Fatalf("секреты после расшифровки: %v", opened.secrets)
+	}
+
+	// Повторное сохранение берёт новый nonce
+	nonce := file.Nonce
+	if err := opened.save(); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Equal(opened.file.Nonce, nonce) {
+		t.Fatal("nonce повторно использован")
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	vault := cheapVault(t, "верный")
+	vault.secrets["sftp/prod"] = "x"
+	if err := vault.save(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := loadVaultFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openVault(file, "неверный"); !errors.Is(err, errVaultPassphrase) {
+		t.Fatalf("ожидалась errVaultPassphrase, получено %v", err)
+	}
+}
+
+func TestVaultTamper(t *testing.T) {
+	vault := cheapVault(t, "пароль")
+	vault.secrets["sftp/prod"] = "x"
+	if err := vault.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func(f *vaultFile){
+		"добавлена запись":     func(f *vaultFile) { f.Entries = append(f.Entries, "sftp/other") },
+		"удалена запись":       func(f *vaultFile) { f.Entries = nil },
+		"переименована запись": func(f *vaultFile) { f.Entries = []string{"sftp/evil"} },
+		"ослаблен KDF":         func(f *vaultFile) { f.Time = 0 },
+		"изменены потоки":      func(f *vaultFile) { f.Threads = 2 },
+		"нет потоков":          func(f *vaultFile) { f.Threads = 0 },
+		"огромная память":      func(f *vaultFile) { f.Memory = 1 << 31 },
+		"изменена соль":        func(f *vaultFile) { f.Salt[0] ^= 1 },
+		"изменены данные":      func(f *vaultFile) { f.Data[0] ^= 1 },
+		"изменён nonce":        func(f *vaultFile) { f.Nonce[0] ^= 1 },
+		"неизвестный KDF":      func(f *vaultFile) { f.KDF = "scrypt" },
+		"nonce неверной длины": func(f *vaultFile) { f.Nonce = f.Nonce[:8] },
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, err := loadVaultFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tamper(file)
+			if _, err := openVault(file, "пароль"); err == nil {
+				t.Fatal("подменённое хранилище открылось")
+			}
+		})
+	}
+
+	// Без подмены то же хранилище открывается
+	file, err := loadVaultFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openVault(file, "пароль"); err != nil {
+		t.Fatalf("openVault: %v", err)
+	}
+}
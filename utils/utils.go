@@ -127,7 +127,64 @@ func isLikelyBinary(filename string) bool {
 }
 
 func IsZipArchive(filename string) bool {
-	return strings.HasSuffix(strings.ToLower(filename), ".zip")
+	lower := strings.ToLower(filename)
+	for _, ext := range models.ZipExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func IsTarArchive(filename string) bool {
+	lower := strings.ToLower(filename)
+	for _, ext := range models.TarExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPackage проверяет расширение пакета (.deb, .rpm)
+func IsPackage(filename, ext string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ext)
+}
+
+// IsDiskImage проверяет расширение образа диска (.iso)
+func IsDiskImage(filename string) bool {
+	lower := strings.ToLower(filename)
+	for _, ext := range models.DiskImageExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveBaseName возвращает имя архива без расширения архива
+func ArchiveBaseName(filename string) string {
+	lower := strings.ToLower(filename)
+	exts := slices.Concat(models.ZipExtensions, models.TarExtensions, models.PackageExtensions, models.DiskImageExtensions)
+	for _, ext := range exts {
+		if strings.HasSuffix(lower, ext) && len(filename) > len(ext) {
+			return filename[:len(filename)-len(ext)]
+		}
+	}
+	return filename
+}
+
+// IsArchive проверяет, можно ли открыть файл как директорию
+func IsArchive(filename string) bool {
+	if IsZipArchive(filename) || IsTarArchive(filename) || IsDiskImage(filename) {
+		return true
+	}
+	for _, ext := range models.PackageExtensions {
+		if IsPackage(filename, ext) {
+			return true
+		}
+	}
+	return false
 }
 
 // ===================== Инициализация интерфейса =====================
@@ -181,6 +238,41 @@ func LoadStylesConfig() (*models.StylesConfig, error) {
 	return &config, nil
 }
 
+// LoadArchivesConfig загружает список расширений zip-контейнеров,
+// создавая файл со значениями по умолчанию при первом запуске
+func LoadArchivesConfig() error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(homeDir, models.ArchivesFile)
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		config := models.ArchivesConfig{ZipExtensions: models.ZipExtensions}
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(configPath, data, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	var config models.ArchivesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	if len(config.ZipExtensions) > 0 {
+		models.ZipExtensions = nil
+		for _, ext := range config.ZipExtensions {
+			models.ZipExtensions = append(models.ZipExtensions, strings.ToLower(ext))
+		}
+	}
+	return nil
+}
+
 // Инициализация стилей на основе конфигурации
 func InitStyles(config *models.StylesConfig) {
 	models.Stls.Title = lipgloss.NewStyle().
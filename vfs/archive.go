@@ -0,0 +1,66 @@
+package vfs
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/KharpukhaevV/filemanager/utils"
+	"io"
+	"os"
+)
+
+// ===================== Открытие архивов =====================
+
+// OpenArchive открывает архив name из fsys как файловую систему
+func OpenArchive(fsys FileSystem, name string) (FileSystem, error) {
+	switch {
+	case utils.IsZipArchive(name):
+		return OpenZip(fsys, name)
+	case utils.IsTarArchive(name):
+		t, err := OpenTar(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if !IsImageLayout(t) {
+			return t, nil
+		}
+		// Если образ не удалось разобрать, архив показывается как обычный tar
+		if image, err := OpenImage(t); err == nil {
+			return image, nil
+		}
+		return t, nil
+	case utils.IsPackage(name, ".deb"):
+		return OpenDeb(fsys, name)
+	case utils.IsPackage(name, ".rpm"):
+		return OpenRPM(fsys, name)
+	case utils.IsDiskImage(name):
+		return OpenISO(fsys, name)
+	case isZipFile(fsys, name):
+		return OpenZip(fsys, name)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый формат архива: %s", name)
+	}
+}
+
+// IsArchive проверяет, можно ли открыть файл как архив:
+// по расширению или, для неизвестных расширений, по сигнатуре zip
+func IsArchive(fsys FileSystem, name string, info os.FileInfo) bool {
+	if utils.IsArchive(name) {
+		return true
+	}
+	return info.Mode().IsRegular() && isZipFile(fsys, name)
+}
+
+// isZipFile проверяет сигнатуру локального заголовка или пустого zip-архива
+func isZipFile(fsys FileSystem, name string) bool {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, []byte("PK\x03\x04")) || bytes.Equal(magic, []byte("PK\x05\x06"))
+}
@@ -0,0 +1,102 @@
+package vfs
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ===================== Сжатые потоки =====================
+
+// Compression определяет алгоритм сжатия потока
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionBzip2
+	CompressionXz
+	CompressionZstd
+)
+
+// streamExtensions сопоставляет расширения одиночных сжатых файлов с алгоритмом
+var streamExtensions = map[string]Compression{
+	".gz":  CompressionGzip,
+	".bz2": CompressionBzip2,
+	".xz":  CompressionXz,
+	".zst": CompressionZstd,
+}
+
+// tarCompression определяет сжатие tar-архива по его имени
+func tarCompression(name string) Compression {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".tgz"):
+		return CompressionGzip
+	case strings.HasSuffix(lower, ".bz2"), strings.HasSuffix(lower, ".tbz2"), strings.HasSuffix(lower, ".tbz"):
+		return CompressionBzip2
+	case strings.HasSuffix(lower, ".xz"), strings.HasSuffix(lower, ".txz"):
+		return CompressionXz
+	case strings.HasSuffix(lower, ".zst"), strings.HasSuffix(lower, ".tzst"):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// CompressedStream определяет по расширению, является ли файл одиночным сжатым потоком
+// (например, app.log.3.gz), и возвращает имя файла после распаковки
+func CompressedStream(name string) (string, Compression) {
+	for ext, c := range streamExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) && len(name) > len(ext) {
+			return name[:len(name)-len(ext)], c
+		}
+	}
+	return name, CompressionNone
+}
+
+// DetectCompression определяет алгоритм сжатия по первым байтам потока
+func DetectCompression(header []byte) Compression {
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return CompressionGzip
+	case bytes.HasPrefix(header, []byte("BZh")):
+		return CompressionBzip2
+	case bytes.HasPrefix(header, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return CompressionXz
+	case bytes.HasPrefix(header, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return CompressionZstd
+	default:
+		return CompressionNone
+	}
+}
+
+// Decompress оборачивает поток r распаковщиком для алгоритма c.
+// Закрытие результата освобождает только ресурсы распаковщика, но не r.
+func Decompress(r io.Reader, c Compression) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case CompressionXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,180 @@
+package vfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ===================== Cpio-архивы =====================
+
+// NewCpioFS читает оглавление cpio-архива формата newc (используется в RPM)
+func NewCpioFS(open func() (io.ReadCloser, error), compression Compression) (*StreamFS, error) {
+	return newStreamFS("cpio", open, compression, func(r io.Reader) streamIterator {
+		return &cpioIterator{r: bufio.NewReader(r), links: make(map[cpioInode][]*streamEntry)}
+	})
+}
+
+// cpioIterator читает записи cpio-архива формата newc ("070701" и "070702")
+type cpioIterator struct {
+	r         *bufio.Reader
+	remaining int64 // непрочитанные данные текущей записи
+	padding   int64 // выравнивание после данных текущей записи
+	// Жёсткие ссылки newc: все имена файла имеют общие устройство и inode,
+	// данные записаны только у последнего, у предыдущих размер нулевой
+	links map[cpioInode][]*streamEntry
+}
+
+// cpioInode — устройство и inode файла
+type cpioInode struct {
+	ino, devMajor, devMinor int64
+}
+
+const (
+	cpioHeaderSize = 110
+	cpioMaxName    = 4096 // PATH_MAX
+)
+
+func (c *cpioIterator) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if err == io.EOF && c.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// skip пропускает остаток данных предыдущей записи
+func (c *cpioIterator) skip() error {
+	if _, err := c.r.Discard(int(c.remaining + c.padding)); err != nil {
+		return err
+	}
+	c.remaining, c.padding = 0, 0
+	return nil
+}
+
+func (c *cpioIterator) Next() (*streamEntry, error) {
+	if err := c.skip(); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, cpioHeaderSize)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	magic := string(header[:6])
+	if magic != "070701" && magic != "070702" {
+		return nil, fmt.Errorf("неподдерживаемый формат cpio: %q", magic)
+	}
+
+	field := func(i int) (int64, error) {
+		return strconv.ParseInt(string(header[6+i*8:14+i*8]), 16, 64)
+	}
+	var values [13]int64
+	for i := range values {
+		v, err := field(i)
+		if err != nil {
+			return nil, fmt.Errorf("повреждённый заголовок cpio: %v", err)
+		}
+		values[i] = v
+	}
+	mode, nlink, mtime, size, nameSize := values[1], values[4], values[5], values[6], values[11]
+	if size < 0 {
+		return nil, fmt.Errorf("повреждённый размер в заголовке cpio: %d", size)
+	}
+	if nameSize <= 0 || nameSize > cpioMaxName {
+		return nil, fmt.Errorf("повреждённое имя в заголовке cpio: длина %d", nameSize)
+	}
+
+	name := make([]byte, nameSize)
+	if _, err := io.ReadFull(c.r, name); err != nil {
+		return nil, err
+	}
+	if len(name) > 0 {
+		name = name[:len(name)-1]
+	}
+	if _, err := c.r.Discard(int(pad4(cpioHeaderSize + nameSize))); err != nil {
+		return nil, err
+	}
+	if string(name) == "TRAILER!!!" {
+		return nil, io.EOF
+	}
+
+	c.remaining = size
+	c.padding = pad4(size)
+
+	entry := &streamEntry{
+		name:   string(name),
+		offset: -1,
+		info: &fileInfo{
+			name:    string(name),
+			size:    size,
+			mode:    cpioMode(mode),
+			modTime: time.Unix(mtime, 0),
+		},
+	}
+	if entry.info.Mode()&os.ModeSymlink != 0 {
+		target, err := io.ReadAll(c)
+		if err != nil {
+			return nil, err
+		}
+		entry.linkname = string(target)
+	}
+	if entry.info.Mode().IsRegular() && nlink > 1 {
+		c.link(cpioInode{ino: values[0], devMajor: values[7], devMinor: values[8]}, entry)
+	}
+	return entry, nil
+}
+
+// link связывает имена одного файла: пустые записи, встреченные раньше,
+// становятся жёсткими ссылками на запись с данными
+func (c *cpioIterator) link(inode cpioInode, entry *streamEntry) {
+	if entry.info.Size() == 0 {
+		c.links[inode] = append(c.links[inode], entry)
+		return
+	}
+	for _, earlier := range c.links[inode] {
+		earlier.hardlink = true
+		earlier.linkname = entry.name
+		earlier.info.(*fileInfo).size = entry.info.Size()
+	}
+	delete(c.links, inode)
+}
+
+// pad4 возвращает число байт выравнивания до границы 4 байт
+func pad4(n int64) int64 {
+	return (4 - n%4) % 4
+}
+
+// cpioMode преобразует режим файла Unix в os.FileMode
+func cpioMode(mode int64) os.FileMode {
+	perm := os.FileMode(mode & 0777)
+	switch mode & 0170000 {
+	case 0040000:
+		return perm | os.ModeDir
+	case 0120000:
+		return perm | os.ModeSymlink
+	case 0010000:
+		return perm | os.ModeNamedPipe
+	case 0020000:
+		return perm | os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		return perm | os.ModeDevice
+	case 0140000:
+		return perm | os.ModeSocket
+	default:
+		return perm
+	}
+}
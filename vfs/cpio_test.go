@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// cpioHeader собирает заголовок newc с заданными размером данных и длиной имени
+func cpioHeader(size, nameSize int64) string {
+	return cpioLinkHeader(0, 1, size, nameSize)
+}
+
+// cpioLinkHeader собирает заголовок newc файла с заданными inode и числом ссылок
+func cpioLinkHeader(ino, nlink, size, nameSize int64) string {
+	fields := []int64{ino, 0100644, 0, 0, nlink, 0, size, 0, 0, 0, 0, nameSize, 0}
+	var sb strings.Builder
+	sb.WriteString("070701")
+	for _, field := range fields {
+		fmt.Fprintf(&sb, "%08x", uint32(field))
+	}
+	return sb.String()
+}
+
+func TestCpioNext(t *testing.T) {
+	data := cpioHeader(3, 6) + "a.txt\x00" + "abc" + "\x00" + cpioHeader(0, 11) + "TRAILER!!!\x00"
+	it := &cpioIterator{r: bufio.NewReader(strings.NewReader(data))}
+	entry, err := it.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if entry.name != "a.txt" || entry.info.Size() != 3 {
+		t.Fatalf("неверная запись: %s, %d", entry.name, entry.info.Size())
+	}
+	if _, err := it.Next(); err != io.EOF {
+		t.Fatalf("ожидался конец архива, получено %v", err)
+	}
+
+	corrupt := map[string]string{
+		"нулевая длина имени":   cpioHeader(0, 0),
+		"огромная длина имени":  cpioHeader(0, 0x7fffffff),
+		"длина имени больше 4K": cpioHeader(0, cpioMaxName+1) + strings.Repeat("a", cpioMaxName+1),
+		"усечённое имя":         cpioHeader(0, 100) + "abc",
+	}
+	for name, data := range corrupt {
+		t.Run(name, func(t *testing.T) {
+			it := &cpioIterator{r: bufio.NewReader(strings.NewReader(data))}
+			if _, err := it.Next(); err == nil || err == io.EOF {
+				t.Fatalf("ожидалась ошибка, получено %v", err)
+			}
+		})
+	}
+}
+
+func TestCpioHardlinks(t *testing.T) {
+	// Три имени файла с inode 7: данные записаны только у последнего
+	data := cpioLinkHeader(7, 3, 0, 2) + "a\x00" +
+		cpioLinkHeader(7, 3, 0, 2) + "b\x00" +
+		cpioLinkHeader(9, 1, 1, 2) + "x\x00" + "x" + "\x00\x00\x00" +
+		cpioLinkHeader(7, 3, 3, 2) + "c\x00" + "abc" + "\x00" +
+		cpioHeader(0, 11) + "TRAILER!!!\x00"
+	fsys, err := NewCpioFS(func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(data)), nil
+	}, CompressionNone)
+	if err != nil {
+		t.Fatalf("NewCpioFS: %v", err)
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		info, err := fsys.Stat(name)
+		if err != nil || info.Size() != 3 {
+			t.Fatalf("Stat %s: %v, %v", name, info, err)
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatalf("Open %s: %v", name, err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(content) != "abc" {
+			t.Fatalf("%s: прочитано %q, %v", name, content, err)
+		}
+	}
+	if f, err := fsys.Open("x"); err != nil {
+		t.Fatalf("Open x: %v", err)
+	} else if content, _ := io.ReadAll(f); string(content) != "x" {
+		t.Fatalf("x: прочитано %q", content)
+	}
+}
@@ -0,0 +1,139 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===================== Пакеты Debian =====================
+
+// arMember описывает член ar-архива
+type arMember struct {
+	name    string
+	offset  int64
+	size    int64
+	modTime time.Time
+}
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// readAr читает оглавление ar-архива (формат GNU и BSD)
+func readAr(ra io.ReaderAt, size int64) ([]arMember, error) {
+	magic := make([]byte, len(arMagic))
+	if _, err := ra.ReadAt(magic, 0); err != nil || string(magic) != arMagic {
+		return nil, errors.New("файл не является ar-архивом")
+	}
+
+	var members []arMember
+	header := make([]byte, arHeaderSize)
+	for offset := int64(len(arMagic)); offset+arHeaderSize <= size; {
+		if _, err := ra.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+		if string(header[58:60]) != "`\n" {
+			return nil, fmt.Errorf("повреждённый заголовок ar по смещению %d", offset)
+		}
+
+		name := strings.TrimSpace(string(header[0:16]))
+		mtime, _ := strconv.ParseInt(strings.TrimSpace(string(header[16:28])), 10, 64)
+		memberSize, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("повреждённый размер в заголовке ar: %v", err)
+		}
+		if memberSize < 0 || offset+arHeaderSize+memberSize > size {
+			return nil, fmt.Errorf("неверный размер члена ar по смещению %d", offset)
+		}
+
+		dataOffset := offset + arHeaderSize
+		dataSize := memberSize
+		// Длинные имена BSD хранятся перед данными: "#1/<длина>"
+		if n, ok := strings.CutPrefix(name, "#1/"); ok {
+			nameLen, err := strconv.ParseInt(n, 10, 64)
+			if err != nil || nameLen < 0 || nameLen > memberSize {
+				return nil, errors.New("повреждённое имя в заголовке ar")
+			}
+			longName := make([]byte, nameLen)
+			if _, err := ra.ReadAt(longName, dataOffset); err != nil {
+				return nil, err
+			}
+			name = string(bytes.TrimRight(longName, "\x00"))
+			dataOffset += nameLen
+			dataSize -= nameLen
+		}
+		name = strings.TrimSuffix(name, "/")
+
+		members = append(members, arMember{
+			name:    name,
+			offset:  dataOffset,
+			size:    dataSize,
+			modTime: time.Unix(mtime, 0),
+		})
+		offset += arHeaderSize + memberSize + memberSize%2
+	}
+	return members, nil
+}
+
+// sectionOpener возвращает функцию, открывающую фрагмент файла как отдельный поток
+func sectionOpener(ra io.ReaderAt, offset, size int64) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(ra, offset, size)), nil
+	}
+}
+
+// OpenDeb открывает пакет .deb: control.tar.* и data.tar.* показываются
+// как директории control и data, остальные члены — как файлы
+func OpenDeb(fsys FileSystem, name string) (*MountFS, error) {
+	ra, size, closer, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := readAr(ra, size)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	var modTime time.Time
+	if len(members) > 0 {
+		modTime = members[0].modTime
+	}
+	pkg := newMountFS(modTime)
+	pkg.closers = append(pkg.closers, closer)
+
+	for _, member := range members {
+		var mountName string
+		switch {
+		case strings.HasPrefix(member.name, "control.tar"):
+			mountName = "control"
+		case strings.HasPrefix(member.name, "data.tar"):
+			mountName = "data"
+		}
+
+		if mountName == "" {
+			data := make([]byte, member.size)
+			if _, err := ra.ReadAt(data, member.offset); err != nil {
+				pkg.Close()
+				return nil, err
+			}
+			pkg.addFile(member.name, data, member.modTime)
+			continue
+		}
+
+		tarFS, err := NewTarFS(sectionOpener(ra, member.offset, member.size), tarCompression(member.name))
+		if err != nil {
+			pkg.Close()
+			return nil, fmt.Errorf("%s: %v", member.name, err)
+		}
+		pkg.mount(mountName, tarFS)
+	}
+	return pkg, nil
+}
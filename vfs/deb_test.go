@@ -0,0 +1,57 @@
+package vfs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// arHeader собирает заголовок члена ar с произвольным полем размера
+func arHeader(name, size string) string {
+	return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, "0", "0", "0", "100644", size)
+}
+
+func TestReadAr(t *testing.T) {
+	valid := arMagic + arHeader("debian-binary", "4") + "2.0\n" +
+		arHeader("#1/8", "11") + "long.txt" + "abc" + "\n"
+
+	members, err := readAr(bytes.NewReader([]byte(valid)), int64(len(valid)))
+	if err != nil {
+		t.Fatalf("readAr: %v", err)
+	}
+	if len(members) != 2 || members[0].name != "debian-binary" || members[1].name != "long.txt" || members[1].size != 3 {
+		t.Fatalf("неверное оглавление: %+v", members)
+	}
+
+	corrupt := map[string]string{
+		"отрицательный размер":   arMagic + arHeader("a", "-60") + "x",
+		"размер больше файла":    arMagic + arHeader("a", "100") + "short",
+		"огромный размер":        arMagic + arHeader("a", "9999999999") + "x",
+		"отрицательная длина":    arMagic + arHeader("#1/-5", "4") + "abcd",
+		"длина больше размера":   arMagic + arHeader("#1/10", "4") + "abcd",
+		"нечисловой размер":      arMagic + arHeader("a", "abc") + "x",
+		"повреждённый заголовок": arMagic + arHeader("a", "1")[:58] + "xx" + "x",
+		"не ar": "garbage!" + arHeader("a", "1"),
+	}
+	for name, data := range corrupt {
+		t.Run(name, func(t *testing.T) {
+			if _, err := readAr(bytes.NewReader([]byte(data)), int64(len(data))); err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+		})
+	}
+}
+
+func TestOpenDebTruncated(t *testing.T) {
+	data := arMagic + arHeader("debian-binary", "4") + "2.0\n" + arHeader("data.tar", "4096") + "trunc"
+	path := filepath.Join(t.TempDir(), "broken.deb")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pkg, err := OpenDeb(NewLocalFS(), path); err == nil {
+		pkg.Close()
+		t.Fatal("ожидалась ошибка для усечённого пакета")
+	}
+}
@@ -0,0 +1,29 @@
+package vfs
+
+import (
+	"os"
+	"time"
+)
+
+// fileInfo — реализация os.FileInfo для виртуальных записей
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() any           { return nil }
+
+// renamedInfo подменяет имя у существующего os.FileInfo
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi *renamedInfo) Name() string { return fi.name }
@@ -0,0 +1,345 @@
+package vfs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// ===================== FTP / FTPS =====================
+
+const ftpTimeout = 15 * time.Second
+
+// FTPConfig описывает подключение к FTP-серверу
+type FTPConfig struct {
+	Addr     string // host:port
+	User     string
+	Password string
+	TLS      bool // явный TLS (AUTH TLS) на управляющем соединении и соединениях данных
+
+	rootCAs *x509.CertPool // доверенные корневые сертификаты; nil — системные
+}
+
+// FTPFS — файловая система на FTP-сервере. Команды выполняются через одно
+// управляющее соединение, а каждая передача файла идёт через отдельное,
+// чтобы навигация не ждала окончания скачивания. Передачи используют
+// пассивный режим (EPSV с откатом на PASV).
+type FTPFS struct {
+	config FTPConfig
+
+	mu   sync.Mutex
+	conn *ftp.ServerConn
+	idle []*ftp.ServerConn // свободные соединения для передач
+}
+
+// NewFTPFS подключается к серверу и выполняет вход
+func NewFTPFS(config FTPConfig) (*FTPFS, error) {
+	f := &FTPFS{config: config}
+	conn, err := f.dial()
+	if err != nil {
+		return nil, err
+	}
+	f.conn = conn
+	return f, nil
+}
+
+func (f *FTPFS) dial() (*ftp.ServerConn, error) {
+	options := []ftp.DialOption{ftp.DialWithTimeout(ftpTimeout)}
+	if f.config.TLS {
+		host, _, err := net.SplitHostPort(f.config.Addr)
+		if err != nil {
+			host = f.config.Addr
+		}
+		options = append(options, ftp.DialWithExplicitTLS(&tls.Config{ServerName: host, RootCAs: f.config.rootCAs}))
+	}
+
+	conn, err := ftp.Dial(f.config.Addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	user := f.config.User
+	if user == "" {
+		user = "anonymous"
+	}
+	if err := conn.Login(user, f.config.Password); err != nil {
+		conn.Quit()
+		return nil, fmt.Errorf("ошибка входа: %v", err)
+	}
+	return conn, nil
+}
+
+// do выполняет команду на управляющем соединении. Если сервер закрыл
+// соединение по таймауту простоя, оно устанавливается заново и команда повторяется.
+func (f *FTPFS) do(fn func(conn *ftp.ServerConn) error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conn != nil {
+		err := fn(f.conn)
+		if err == nil || isFTPReply(err) {
+			return err
+		}
+		f.conn.Quit()
+		f.conn = nil
+	}
+
+	conn, err := f.dial()
+	if err != nil {
+		return err
+	}
+	f.conn = conn
+	return fn(conn)
+}
+
+// isFTPReply отличает ответ сервера с кодом ошибки от обрыва соединения
+func isFTPReply(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply)
+}
+
+// transferConn возвращает соединение для передачи файла
+func (f *FTPFS) transferConn() (*ftp.ServerConn, error) {
+	f.mu.Lock()
+	if n := len(f.idle); n > 0 {
+		conn := f.idle[n-1]
+		f.idle = f.idle[:n-1]
+		f.mu.Unlock()
+		if conn.NoOp() == nil {
+			return conn, nil
+		}
+		conn.Quit()
+	} else {
+		f.mu.Unlock()
+	}
+	return f.dial()
+}
+
+// releaseConn возвращает соединение в пул или закрывает его после ошибки
+func (f *FTPFS) releaseConn(conn *ftp.ServerConn, failed bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if failed || len(f.idle) >= 2 {
+		conn.Quit()
+		return
+	}
+	f.idle = append(f.idle, conn)
+}
+
+func (f *FTPFS) ReadDir(name string) ([]os.FileInfo, error) {
+	var entries []*ftp.Entry
+	err := f.do(func(conn *ftp.ServerConn) error {
+		var err error
+		entries, err = conn.List(filepath.ToSlash(name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+		infos = append(infos, ftpFileInfo(path.Base(entry.Name), entry))
+	}
+	return infos, nil
+}
+
+func (f *FTPFS) Stat(name string) (os.FileInfo, error) {
+	name = path.Clean(filepath.ToSlash(name))
+	if name == "/" || name == "." {
+		return &fileInfo{name: "/", mode: os.ModeDir | 0755}, nil
+	}
+
+	var entry *ftp.Entry
+	err := f.do(func(conn *ftp.ServerConn) error {
+		var err error
+		entry, err = conn.GetEntry(name)
+		return err
+	})
+	if err == nil {
+		return ftpFileInfo(path.Base(name), entry), nil
+	}
+
+	// Сервер без MLST: ищем запись в списке родительской директории
+	infos, err := f.ReadDir(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		if info.Name() == path.Base(name) {
+			return info, nil
+		}
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func ftpFileInfo(name string, entry *ftp.Entry) *fileInfo {
+	info := &fileInfo{name: name, size: int64(entry.Size), mode: 0644, modTime: entry.Time}
+	switch entry.Type {
+	case ftp.EntryTypeFolder:
+		info.mode = os.ModeDir | 0755
+		info.size = 0
+	case ftp.EntryTypeLink:
+		info.mode = os.ModeSymlink | 0777
+	}
+	return info
+}
+
+func (f *FTPFS) Open(name string) (File, error) {
+	return f.OpenFrom(name, 0)
+}
+
+// OpenFrom начинает передачу файла с позиции offset (команда REST). Если
+// сервер не принимает REST, возвращает ErrResumeUnsupported.
+func (f *FTPFS) OpenFrom(name string, offset int64) (File, error) {
+	name = filepath.ToSlash(name)
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s является директорией", name)
+	}
+
+	conn, err := f.transferConn()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := conn.RetrFrom(name, uint64(offset))
+	if err != nil {
+		f.releaseConn(conn, !isFTPReply(err))
+		if offset > 0 && isFTPReply(err) {
+			return nil, fmt.Errorf("%w: %v", ErrResumeUnsupported, err)
+		}
+		return nil, err
+	}
+	return &ftpReadFile{fs: f, conn: conn, resp: resp, info: info}, nil
+}
+
+func (f *FTPFS) Create(name string) (File, error) {
+	return f.store(name, (*ftp.ServerConn).Stor)
+}
+
+// OpenAppend дописывает файл в конец (команда APPE); используется для
+// докачки загрузок
+func (f *FTPFS) OpenAppend(name string) (File, error) {
+	return f.store(name, (*ftp.ServerConn).Append)
+}
+
+// store передаёт записанные в файл данные на сервер командой send
+func (f *FTPFS) store(name string, send func(conn *ftp.ServerConn, path string, r io.Reader) error) (File, error) {
+	name = filepath.ToSlash(name)
+	conn, err := f.transferConn()
+	if err != nil {
+		return nil, err
+	}
+
+	return newPipeFile(name, func(r io.Reader) error {
+		err := send(conn, name, r)
+		f.releaseConn(conn, err != nil && !isFTPReply(err))
+		return err
+	}), nil
+}
+
+func (f *FTPFS) Mkdir(name string) error {
+	return f.do(func(conn *ftp.ServerConn) error {
+		return conn.MakeDir(filepath.ToSlash(name))
+	})
+}
+
+func (f *FTPFS) Rename(oldname, newname string) error {
+	return f.do(func(conn *ftp.ServerConn) error {
+		return conn.Rename(filepath.ToSlash(oldname), filepath.ToSlash(newname))
+	})
+}
+
+func (f *FTPFS) Remove(name string) error {
+	info, err := f.Stat(name)
+	if err != nil {
+		return err
+	}
+	return f.do(func(conn *ftp.ServerConn) error {
+		if info.IsDir() {
+			return conn.RemoveDirRecur(filepath.ToSlash(name))
+		}
+		return conn.Delete(filepath.ToSlash(name))
+	})
+}
+
+func (f *FTPFS) ReadLink(name string) (string, error) {
+	dir := path.Dir(filepath.ToSlash(name))
+	var entries []*ftp.Entry
+	err := f.do(func(conn *ftp.ServerConn) error {
+		var err error
+		entries, err = conn.List(dir)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		if entry.Name == path.Base(name) && entry.Type == ftp.EntryTypeLink {
+			return entry.Target, nil
+		}
+	}
+	return "", fmt.Errorf("%s не является символической ссылкой", name)
+}
+
+// Chmod не поддерживается: SITE CHMOD реализован не на всех серверах
+func (f *FTPFS) Chmod(name string, mode os.FileMode) error {
+	return fmt.Errorf("изменение прав не поддерживается по FTP")
+}
+
+func (f *FTPFS) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, conn := range f.idle {
+		conn.Quit()
+	}
+	f.idle = nil
+	if f.conn == nil {
+		return nil
+	}
+	err := f.conn.Quit()
+	f.conn = nil
+	return err
+}
+
+// ftpReadFile — файл, читаемый через отдельное соединение данных
+type ftpReadFile struct {
+	fs     *FTPFS
+	conn   *ftp.ServerConn
+	resp   *ftp.Response
+	info   os.FileInfo
+	closed bool
+}
+
+func (r *ftpReadFile) Read(p []byte) (int, error) {
+	return r.resp.Read(p)
+}
+
+func (r *ftpReadFile) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (r *ftpReadFile) Stat() (os.FileInfo, error)  { return r.info, nil }
+
+func (r *ftpReadFile) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.resp.Close()
+	r.fs.releaseConn(r.conn, err != nil)
+	return err
+}
@@ -0,0 +1,190 @@
+package vfs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+)
+
+// ftpDriver — FTP-сервер для тестов с файлами в памяти
+type ftpDriver struct {
+	fs  afero.Fs
+	tls *tls.Config
+}
+
+func (d *ftpDriver) GetSettings() (*ftpserver.Settings, error) {
+	return &ftpserver.Settings{ListenAddr: "127.0.0.1:0"}, nil
+}
+
+func (d *ftpDriver) ClientConnected(cc ftpserver.ClientContext) (string, error) {
+	return "test", nil
+}
+
+func (d *ftpDriver) ClientDisconnected(cc ftpserver.ClientContext) {}
+
+func (d *ftpDriver) AuthUser(cc ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
+	if user != "user" || pass != "secret" {
+		return nil, errors.New("неверный пароль")
+	}
+	return d.fs, nil
+}
+
+func (d *ftpDriver) GetTLSConfig() (*tls.Config, error) {
+	if d.tls == nil {
+		return nil, errors.New("TLS не настроен")
+	}
+	return d.tls, nil
+}
+
+// startFTPServer запускает FTP-сервер на свободном порту. Для ftps сертификат
+// берётся у httptest: он выписан на 127.0.0.1.
+func startFTPServer(t *testing.T, withTLS bool) (FTPConfig, afero.Fs) {
+	t.Helper()
+	driver := &ftpDriver{fs: afero.NewMemMapFs()}
+	config := FTPConfig{User: "user", Password: "secret", TLS: withTLS}
+	if withTLS {
+		https := httptest.NewTLSServer(http.NotFoundHandler())
+		t.Cleanup(https.Close)
+		driver.tls = &tls.Config{Certificates: https.TLS.Certificates}
+		config.rootCAs = x509.NewCertPool()
+		config.rootCAs.AddCert(https.Certificate())
+	}
+
+	server := ftpserver.NewFtpServer(driver)
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Stop() })
+	config.Addr = server.Addr()
+	return config, driver.fs
+}
+
+func TestFTPFS(t *testing.T) {
+	for name, withTLS := range map[string]bool{"ftp": false, "ftps": true} {
+		t.Run(name, func(t *testing.T) {
+			config, mem := startFTPServer(t, withTLS)
+			if err := afero.WriteFile(mem, "/docs/readme.txt", []byte("hello"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			fsys, err := NewFTPFS(config)
+			if err != nil {
+				t.Fatalf("NewFTPFS: %v", err)
+			}
+			defer fsys.Close()
+
+			infos, err := fsys.ReadDir("/")
+			if err != nil {
+				t.Fatalf("ReadDir: %v", err)
+			}
+			if len(infos) != 1 || infos[0].Name() != "docs" || !infos[0].IsDir() {
+				t.Fatalf("неверный список: %v", infos)
+			}
+
+			data := readFTPFile(t, fsys, "/docs/readme.txt")
+			if data != "hello" {
+				t.Fatalf("прочитано %q", data)
+			}
+
+			w, err := fsys.Create("/docs/new.txt")
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if _, err := io.WriteString(w, "written over ftp"); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if data := readFTPFile(t, fsys, "/docs/new.txt"); data != "written over ftp" {
+				t.Fatalf("прочитано %q", data)
+			}
+
+			if err := fsys.Rename("/docs/new.txt", "/docs/renamed.txt"); err != nil {
+				t.Fatalf("Rename: %v", err)
+			}
+			if _, err := fsys.Stat("/docs/new.txt"); !os.IsNotExist(err) {
+				t.Fatalf("старое имя осталось: %v", err)
+			}
+			if info, err := fsys.Stat("/docs/renamed.txt"); err != nil || info.Size() != 16 {
+				t.Fatalf("Stat: %v, %v", info, err)
+			}
+
+			if err := fsys.Remove("/docs/renamed.txt"); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if err := fsys.Remove("/docs"); err != nil {
+				t.Fatalf("Remove dir: %v", err)
+			}
+			if exists, _ := afero.DirExists(mem, "/docs"); exists {
+				t.Fatal("директория не удалена")
+			}
+		})
+	}
+}
+
+func TestFTPFSBadPassword(t *testing.T) {
+	config, _ := startFTPServer(t, false)
+	config.Password = "wrong"
+	if fsys, err := NewFTPFS(config); err == nil {
+		fsys.Close()
+		t.Fatal("ожидалась ошибка входа")
+	}
+}
+
+func readFTPFile(t *testing.T, fsys FileSystem, name string) string {
+	t.Helper()
+	r, err := fsys.Open(name)
+	if err != nil {
+		t.Fatalf("Open %s: %v", name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("чтение %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestFTPResume(t *testing.T) {
+	config, mem := startFTPServer(t, false)
+	if err := afero.WriteFile(mem, "/data.bin", []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := NewFTPFS(config)
+	if err != nil {
+		t.Fatalf("NewFTPFS: %v", err)
+	}
+	defer fsys.Close()
+
+	r, err := OpenFrom(fsys, "/data.bin", 6)
+	if err != nil {
+		t.Fatalf("OpenFrom: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "6789" {
+		t.Fatalf("прочитано %q, %v", data, err)
+	}
+
+	w, err := OpenAppend(fsys, "/data.bin")
+	if err != nil {
+		t.Fatalf("OpenAppend: %v", err)
+	}
+	io.WriteString(w, "abc")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if data, _ := afero.ReadFile(mem, "/data.bin"); string(data) != "0123456789abc" {
+		t.Fatalf("после дозаписи %q", data)
+	}
+}
@@ -0,0 +1,437 @@
+package vfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ===================== Образы контейнеров =====================
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	refNameAnnotation    = "org.opencontainers.image.ref.name"
+	imageNameAnnotation  = "io.containerd.image.name"
+	ociIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	dockerListMediaType  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	maxImageMetadataSize = 16 * 1024 * 1024
+)
+
+// imageInfo описывает один образ внутри архива
+type imageInfo struct {
+	name     string
+	manifest []byte
+	config   []byte
+	layers   []string // пути слоёв внутри архива, от нижнего к верхнему
+}
+
+// dockerManifest — запись manifest.json из `docker save`
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// ociDescriptor — дескриптор объекта в OCI-раскладке
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform"`
+}
+
+// ociManifest — манифест образа или индекс манифестов
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+// IsImageLayout проверяет, содержит ли файловая система образ в формате
+// OCI image layout или выгрузку `docker save`
+func IsImageLayout(src FileSystem) bool {
+	if _, err := src.Stat("manifest.json"); err == nil {
+		return true
+	}
+	_, errLayout := src.Stat("oci-layout")
+	_, errIndex := src.Stat("index.json")
+	return errLayout == nil && errIndex == nil
+}
+
+// OpenImage показывает образы из src послойно. Для каждого образа создаются
+// файлы manifest.json и config.json, директория layers со слоями и
+// директория rootfs с объединённой файловой системой, в которой применены
+// whiteout-файлы. Исходное содержимое архива доступно в директории raw.
+func OpenImage(src FileSystem) (*MountFS, error) {
+	images, err := readImages(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, fmt.Errorf("образы не найдены")
+	}
+
+	var modTime time.Time
+	if info, err := src.Stat("manifest.json"); err == nil {
+		modTime = info.ModTime()
+	} else if info, err := src.Stat("index.json"); err == nil {
+		modTime = info.ModTime()
+	}
+
+	// Одинаковые слои разных образов открываются один раз
+	layerCache := make(map[string]*StreamFS)
+	root := newMountFS(modTime)
+	for _, image := range images {
+		fsys, err := newImageFS(src, image, layerCache, modTime)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", image.name, err)
+		}
+		if len(images) == 1 {
+			root = fsys
+			break
+		}
+		root.mount(uniqueName(root, imageDirName(image.name)), fsys)
+	}
+	root.mount("raw", src)
+	return root, nil
+}
+
+// newImageFS собирает директорию одного образа
+func newImageFS(src FileSystem, image imageInfo, layerCache map[string]*StreamFS, modTime time.Time) (*MountFS, error) {
+	fsys := newMountFS(modTime)
+	fsys.addFile("manifest.json", image.manifest, modTime)
+	if image.config != nil {
+		fsys.addFile("config.json", image.config, modTime)
+	}
+
+	layersFS := newMountFS(modTime)
+	var layers []*StreamFS
+	for i, name := range image.layers {
+		layer, ok := layerCache[name]
+		if !ok {
+			var err error
+			if layer, err = openLayer(src, name); err != nil {
+				return nil, fmt.Errorf("слой %s: %v", name, err)
+			}
+			layerCache[name] = layer
+		}
+		layers = append(layers, layer)
+		layersFS.mount(fmt.Sprintf("%02d-%s", i+1, layerID(name)), layer)
+	}
+	fsys.mount("layers", layersFS)
+	fsys.mount("rootfs", newOverlayFS(layers))
+	return fsys, nil
+}
+
+// readImages находит образы: сначала по manifest.json из `docker save`,
+// затем по index.json из OCI-раскладки
+func readImages(src FileSystem) ([]imageInfo, error) {
+	if data, err := readSmallFile(src, "manifest.json"); err == nil {
+		return readDockerImages(src, data)
+	}
+
+	data, err := readSmallFile(src, "index.json")
+	if err != nil {
+		return nil, err
+	}
+	var index ociManifest
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("index.json: %v", err)
+	}
+
+	var images []imageInfo
+	if err := collectOCIImages(src, index.Manifests, "", &images, 0); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func readDockerImages(src FileSystem, data []byte) ([]imageInfo, error) {
+	var manifests []dockerManifest
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, fmt.Errorf("manifest.json: %v", err)
+	}
+
+	var images []imageInfo
+	for i, manifest := range manifests {
+		config, err := readSmallFile(src, manifest.Config)
+		if err != nil {
+			return nil, fmt.Errorf("конфигурация образа: %v", err)
+		}
+		pretty, _ := json.MarshalIndent(manifest, "", "  ")
+
+		name := fmt.Sprintf("image-%d", i+1)
+		if len(manifest.RepoTags) > 0 {
+			name = manifest.RepoTags[0]
+		} else if manifest.Config != "" {
+			name = layerID(manifest.Config)
+		}
+		images = append(images, imageInfo{
+			name:     name,
+			manifest: pretty,
+			config:   config,
+			layers:   manifest.Layers,
+		})
+	}
+	return images, nil
+}
+
+// collectOCIImages обходит дескрипторы индекса; вложенные индексы
+// (многоплатформенные образы) раскрываются рекурсивно
+func collectOCIImages(src FileSystem, descriptors []ociDescriptor, parentName string, images *[]imageInfo, depth int) error {
+	if depth > 8 {
+		return fmt.Errorf("слишком глубокая вложенность индексов")
+	}
+
+	for _, desc := range descriptors {
+		data, err := readSmallFile(src, blobPath(desc.Digest))
+		if err != nil {
+			return err
+		}
+		var manifest ociManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return fmt.Errorf("%s: %v", desc.Digest, err)
+		}
+
+		name := descriptorName(desc, parentName)
+		mediaType := desc.MediaType
+		if mediaType == "" {
+			mediaType = manifest.MediaType
+		}
+		if mediaType == ociIndexMediaType || mediaType == dockerListMediaType || manifest.Manifests != nil {
+			if err := collectOCIImages(src, manifest.Manifests, name, images, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		config, err := readSmallFile(src, blobPath(manifest.Config.Digest))
+		if err != nil {
+			return fmt.Errorf("конфигурация образа: %v", err)
+		}
+		image := imageInfo{name: name, manifest: data, config: config}
+		for _, layer := range manifest.Layers {
+			image.layers = append(image.layers, blobPath(layer.Digest))
+		}
+		*images = append(*images, image)
+	}
+	return nil
+}
+
+// descriptorName выбирает имя образа: тег из аннотаций, платформа или дайджест
+func descriptorName(desc ociDescriptor, parentName string) string {
+	for _, key := range []string{refNameAnnotation, imageNameAnnotation} {
+		if name := desc.Annotations[key]; name != "" {
+			return name
+		}
+	}
+	if desc.Platform != nil && desc.Platform.OS != "" {
+		platform := desc.Platform.OS + "-" + desc.Platform.Architecture
+		if parentName != "" {
+			return parentName + "-" + platform
+		}
+		return platform
+	}
+	return layerID(desc.Digest)
+}
+
+// blobPath переводит дайджест "sha256:abc" в путь blobs/sha256/abc
+func blobPath(digest string) string {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok {
+		return digest
+	}
+	return path.Join("blobs", algorithm, hex)
+}
+
+// layerID возвращает короткий идентификатор слоя или объекта по его пути
+func layerID(name string) string {
+	name = cleanEntryName(name)
+	base := path.Base(name)
+	if base == "layer.tar" {
+		base = path.Base(parentDir(name))
+	}
+	if _, hex, ok := strings.Cut(base, ":"); ok {
+		base = hex
+	}
+	base = strings.TrimSuffix(base, ".json")
+	if len(base) > 12 {
+		base = base[:12]
+	}
+	return base
+}
+
+// imageDirName делает из тега образа допустимое имя директории
+func imageDirName(name string) string {
+	return strings.NewReplacer("/", "_", ":", "_").Replace(name)
+}
+
+func uniqueName(m *MountFS, name string) string {
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := m.mounts[candidate]; !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// readSmallFile читает служебный JSON-файл образа целиком
+func readSmallFile(src FileSystem, name string) ([]byte, error) {
+	f, err := src.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxImageMetadataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxImageMetadataSize {
+		return nil, fmt.Errorf("%s: файл слишком большой", name)
+	}
+	return data, nil
+}
+
+// openLayer открывает слой образа; сжатие определяется по сигнатуре
+func openLayer(src FileSystem, name string) (*StreamFS, error) {
+	f, err := src.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, 6)
+	n, err := io.ReadFull(f, magic)
+	f.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return openTar(src, name, DetectCompression(magic[:n]))
+}
+
+// ===================== Объединённая файловая система слоёв =====================
+
+// overlayEntry — запись объединённой файловой системы и слой, из которого она взята
+type overlayEntry struct {
+	layer *StreamFS
+	name  string
+}
+
+// overlayFS показывает слои образа, наложенные друг на друга, как это делает
+// среда выполнения контейнеров: верхние слои заменяют файлы нижних,
+// ".wh.name" удаляет name, а ".wh..wh..opq" скрывает содержимое директории
+// из нижних слоёв
+type overlayFS struct {
+	files map[string]overlayEntry
+	tree  *archiveTree
+}
+
+func newOverlayFS(layers []*StreamFS) *overlayFS {
+	files := make(map[string]overlayEntry)
+	infos := make(map[string]os.FileInfo)
+	remove := func(name string, self bool) {
+		if self {
+			delete(files, name)
+			delete(infos, name)
+		}
+		prefix := name + "/"
+		for entry := range files {
+			if name == "" || strings.HasPrefix(entry, prefix) {
+				delete(files, entry)
+				delete(infos, entry)
+			}
+		}
+	}
+
+	for _, layer := range layers {
+		// Whiteout-файлы действуют только на нижние слои, поэтому применяются до добавления записей слоя
+		for _, entry := range layer.entries {
+			dir, base := parentDir(entry.name), path.Base(entry.name)
+			switch {
+			case base == whiteoutOpaque:
+				remove(dir, false)
+			case strings.HasPrefix(base, whiteoutPrefix):
+				remove(path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), true)
+			}
+		}
+
+		for _, entry := range layer.entries {
+			if strings.HasPrefix(path.Base(entry.name), whiteoutPrefix) {
+				continue
+			}
+			if old, ok := infos[entry.name]; ok && old.IsDir() && !entry.info.IsDir() {
+				remove(entry.name, false)
+			}
+			files[entry.name] = overlayEntry{layer: layer, name: entry.name}
+			infos[entry.name] = entry.info
+		}
+	}
+
+	// Родительские директории добавляются раньше вложенных записей
+	names := make([]string, 0, len(infos))
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tree := newArchiveTree()
+	for _, name := range names {
+		tree.add(name, infos[name])
+	}
+	return &overlayFS{files: files, tree: tree}
+}
+
+func (o *overlayFS) lookup(op, name string) (overlayEntry, error) {
+	entry, ok := o.files[cleanEntryName(name)]
+	if !ok {
+		return entry, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return entry, nil
+}
+
+func (o *overlayFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return o.tree.readDir(name)
+}
+
+func (o *overlayFS) Stat(name string) (os.FileInfo, error) {
+	return o.tree.stat(name)
+}
+
+func (o *overlayFS) Open(name string) (File, error) {
+	entry, err := o.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.layer.Open(entry.name)
+}
+
+func (o *overlayFS) ReadLink(name string) (string, error) {
+	entry, err := o.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return entry.layer.ReadLink(entry.name)
+}
+
+func (o *overlayFS) archive() {}
+
+func (o *overlayFS) Create(name string) (File, error)          { return nil, ErrReadOnly }
+func (o *overlayFS) Mkdir(name string) error                   { return ErrReadOnly }
+func (o *overlayFS) Rename(oldname, newname string) error      { return ErrReadOnly }
+func (o *overlayFS) Remove(name string) error                  { return ErrReadOnly }
+func (o *overlayFS) Chmod(name string, mode os.FileMode) error { return ErrReadOnly }
+
+// Close ничего не делает: слои закрываются вместе с директорией layers
+func (o *overlayFS) Close() error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package vfs
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+)
+
+// buildTar собирает tar-архив из пар имя → содержимое; имена с "/" на конце — директории
+func buildTar(t *testing.T, files [][2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range files {
+		header := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}
+		if file[0][len(file[0])-1] == '/' {
+			header = &tar.Header{Name: file[0], Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// ociLayout собирает OCI image layout с одним образом из слоёв layers
+func ociLayout(t *testing.T, layers ...[]byte) FileSystem {
+	t.Helper()
+	var files [][2]string
+	blob := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		files = append(files, [2]string{"blobs/sha256/" + digest, string(data)})
+		return "sha256:" + digest
+	}
+	descriptor := func(mediaType, digest string) map[string]any {
+		return map[string]any{"mediaType": mediaType, "digest": digest}
+	}
+	marshal := func(v any) []byte {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var layerDescriptors []map[string]any
+	for _, layer := range layers {
+		layerDescriptors = append(layerDescriptors, descriptor("application/vnd.oci.image.layer.v1.tar", blob(layer)))
+	}
+	manifest := blob(marshal(map[string]any{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"config":        descriptor("application/vnd.oci.image.config.v1+json", blob([]byte("{}"))),
+		"layers":        layerDescriptors,
+	}))
+	index := marshal(map[string]any{
+		"schemaVersion": 2,
+		"manifests":     []any{descriptor("application/vnd.oci.image.manifest.v1+json", manifest)},
+	})
+	files = append(files,
+		[2]string{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		[2]string{"index.json", string(index)},
+	)
+
+	data := buildTar(t, files)
+	src, err := NewTarFS(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, CompressionNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestImageWhiteouts(t *testing.T) {
+	lower := buildTar(t, [][2]string{
+		{"etc/", ""},
+		{"etc/keep.txt", "keep"},
+		{"etc/gone.txt", "gone"},
+		{"opt/", ""},
+		{"opt/a.txt", "a"},
+		{"opt/sub/b.txt", "b"},
+		{"data/file.txt", "v1"},
+	})
+	upper := buildTar(t, [][2]string{
+		{"etc/.wh.gone.txt", ""},
+		{"opt/.wh..wh..opq", ""},
+		{"opt/new.txt", "new"},
+		{"data/.wh.file.txt", ""},
+		{"data/file.txt", "v2"},
+	})
+
+	src := ociLayout(t, lower, upper)
+	if !IsImageLayout(src) {
+		t.Fatal("OCI layout не распознан")
+	}
+	image, err := OpenImage(src)
+	if err != nil {
+		t.Fatalf("OpenImage: %v", err)
+	}
+	defer image.Close()
+
+	read := func(name string) string {
+		f, err := image.Open(name)
+		if err != nil {
+			t.Fatalf("Open %s: %v", name, err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	names := func(dir string) []string {
+		infos, err := image.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir %s: %v", dir, err)
+		}
+		var result []string
+		for _, info := range infos {
+			result = append(result, info.Name())
+		}
+		return result
+	}
+
+	if got := read("rootfs/etc/keep.txt"); got != "keep" {
+		t.Fatalf("файл нижнего слоя: %q", got)
+	}
+	if _, err := image.Stat("rootfs/etc/gone.txt"); !os.IsNotExist(err) {
+		t.Fatalf("файл, удалённый .wh., остался: %v", err)
+	}
+	if got := names("rootfs/etc"); len(got) != 1 || got[0] != "keep.txt" {
+		t.Fatalf("содержимое etc: %v", got)
+	}
+	// Непрозрачная директория скрывает всё содержимое нижних слоёв
+	if got := names("rootfs/opt"); len(got) != 1 || got[0] != "new.txt" {
+		t.Fatalf("содержимое непрозрачной opt: %v", got)
+	}
+	if _, err := image.Stat("rootfs/opt/sub/b.txt"); !os.IsNotExist(err) {
+		t.Fatalf("вложенный файл непрозрачной директории остался: %v", err)
+	}
+	if got := read("rootfs/data/file.txt"); got != "v2" {
+		t.Fatalf("заново добавленный файл: %q", got)
+	}
+
+	// Сами слои показываются без изменений, вместе с whiteout-файлами
+	layers := names("layers")
+	if len(layers) != 2 {
+		t.Fatalf("слои: %v", layers)
+	}
+	if _, err := image.Stat("layers/" + layers[1] + "/etc/.wh.gone.txt"); err != nil {
+		t.Fatalf("whiteout-файл в слое: %v", err)
+	}
+}
@@ -0,0 +1,602 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ===================== Образы ISO 9660 =====================
+
+const (
+	isoSectorSize     = 2048
+	isoFirstVolume    = 16
+	isoMaxVolumes     = 64
+	isoMaxDirectories = 1 << 20
+
+	isoFlagDir         = 0x02
+	isoFlagMultiExtent = 0x80
+)
+
+// isoExtent — непрерывный участок данных файла внутри образа
+type isoExtent struct {
+	offset int64
+	size   int64
+}
+
+// isoEntry — файл или директория образа
+type isoEntry struct {
+	info     os.FileInfo
+	extents  []isoExtent
+	linkname string
+}
+
+// ISOFS — файловая система образа ISO 9660 только для чтения.
+// Имена берутся из расширения Rock Ridge, если оно есть, затем из Joliet,
+// иначе используются короткие имена ISO 9660. Данные читаются по смещениям,
+// поэтому образ на SFTP-сервере не загружается целиком.
+type ISOFS struct {
+	ra      io.ReaderAt
+	size    int64 // размер образа
+	closer  io.Closer
+	entries map[string]*isoEntry
+	tree    *archiveTree
+}
+
+// isoRecord — разобранная запись директории
+type isoRecord struct {
+	name    string
+	extent  int64
+	size    int64
+	flags   byte
+	modTime time.Time
+	system  []byte // область System Use с записями SUSP
+	isDot   bool
+}
+
+// isoVolume описывает дерево, которое будет прочитано
+type isoVolume struct {
+	root      isoRecord
+	joliet    bool
+	rockRidge bool
+	suspSkip  int
+}
+
+// OpenISO открывает образ ISO 9660 из fsys
+func OpenISO(fsys FileSystem, name string) (*ISOFS, error) {
+	ra, size, closer, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	iso, err := NewISOFS(ra, size)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	iso.closer = closer
+	return iso, nil
+}
+
+// NewISOFS читает каталог образа ISO 9660 размером size из ra
+func NewISOFS(ra io.ReaderAt, size int64) (*ISOFS, error) {
+	volume, err := readISOVolume(ra)
+	if err != nil {
+		return nil, err
+	}
+
+	iso := &ISOFS{
+		ra:      ra,
+		size:    size,
+		entries: make(map[string]*isoEntry),
+		tree:    newArchiveTree(),
+	}
+	if err := iso.load(volume); err != nil {
+		return nil, err
+	}
+	return iso, nil
+}
+
+// readISOVolume выбирает дерево каталогов: Rock Ridge в основном томе,
+// затем дополнительный том Joliet, затем основной том без расширений
+func readISOVolume(ra io.ReaderAt) (*isoVolume, error) {
+	var primary, joliet *isoVolume
+	sector := make([]byte, isoSectorSize)
+
+	for i := int64(isoFirstVolume); i < isoFirstVolume+isoMaxVolumes; i++ {
+		if _, err := ra.ReadAt(sector, i*isoSectorSize); err != nil {
+			return nil, fmt.Errorf("ошибка чтения дескриптора тома: %v", err)
+		}
+		if !bytes.Equal(sector[1:6], []byte("CD001")) {
+			return nil, fmt.Errorf("не является образом ISO 9660")
+		}
+
+		switch sector[0] {
+		case 1:
+			root, err := parseISORecord(sector[156:190], false)
+			if err != nil {
+				return nil, err
+			}
+			primary = &isoVolume{root: root}
+		case 2:
+			// Escape-последовательности UCS-2 уровней 1–3 обозначают Joliet
+			escape := sector[88:91]
+			if escape[0] == '%' && escape[1] == '/' && (escape[2] == '@' || escape[2] == 'C' || escape[2] == 'E') {
+				root, err := parseISORecord(sector[156:190], true)
+				if err != nil {
+					return nil, err
+				}
+				joliet = &isoVolume{root: root, joliet: true}
+			}
+		}
+		if sector[0] == 255 {
+			break
+		}
+	}
+
+	if primary == nil {
+		return nil, fmt.Errorf("в образе нет основного дескриптора тома")
+	}
+
+	// Признак Rock Ridge — запись SP в области System Use записи "." корня
+	if skip, ok := detectSUSP(ra, primary.root); ok {
+		primary.rockRidge = true
+		primary.suspSkip = skip
+		return primary, nil
+	}
+	if joliet != nil {
+		return joliet, nil
+	}
+	return primary, nil
+}
+
+// detectSUSP ищет запись SP в первой записи корневой директории
+func detectSUSP(ra io.ReaderAt, root isoRecord) (int, bool) {
+	sector := make([]byte, isoSectorSize)
+	if _, err := ra.ReadAt(sector, root.extent*isoSectorSize); err != nil {
+		return 0, false
+	}
+	record, err := parseISORecord(sector, false)
+	if err != nil || !record.isDot {
+		return 0, false
+	}
+	su := record.system
+	if len(su) >= 7 && su[0] == 'S' && su[1] == 'P' && su[4] == 0xBE && su[5] == 0xEF {
+		return int(su[6]), true
+	}
+	return 0, false
+}
+
+// parseISORecord разбирает запись директории, начинающуюся в data
+func parseISORecord(data []byte, joliet bool) (isoRecord, error) {
+	if len(data) < 34 || int(data[0]) > len(data) || data[0] < 34 {
+		return isoRecord{}, fmt.Errorf("повреждённая запись директории")
+	}
+	length := int(data[0])
+	nameLen := int(data[32])
+	if 33+nameLen > length {
+		return isoRecord{}, fmt.Errorf("повреждённая запись директории")
+	}
+
+	record := isoRecord{
+		extent:  int64(binary.LittleEndian.Uint32(data[2:6])),
+		size:    int64(binary.LittleEndian.Uint32(data[10:14])),
+		flags:   data[25],
+		modTime: isoRecordTime(data[18:25]),
+	}
+
+	rawName := data[33 : 33+nameLen]
+	if nameLen == 1 && (rawName[0] == 0 || rawName[0] == 1) {
+		record.isDot = true
+	} else if joliet {
+		record.name = decodeUCS2(rawName)
+	} else {
+		record.name = string(rawName)
+	}
+	record.name = isoTrimVersion(record.name)
+
+	systemStart := 33 + nameLen
+	if nameLen%2 == 0 {
+		systemStart++
+	}
+	if systemStart < length {
+		record.system = data[systemStart:length]
+	}
+	return record, nil
+}
+
+// isoTrimVersion убирает номер версии ";1" и завершающую точку имени без расширения
+func isoTrimVersion(name string) string {
+	if i := strings.LastIndexByte(name, ';'); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+func decodeUCS2(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// isoRecordTime разбирает 7-байтовую дату записи директории
+func isoRecordTime(data []byte) time.Time {
+	if data[0] == 0 && data[1] == 0 {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(data[6]))*15*60)
+	return time.Date(1900+int(data[0]), time.Month(data[1]), int(data[2]),
+		int(data[3]), int(data[4]), int(data[5]), 0, zone)
+}
+
+// isoLongTime разбирает 17-байтовую дату в текстовом формате
+func isoLongTime(data []byte) time.Time {
+	t, err := time.Parse("20060102150405", string(data[:14]))
+	if err != nil {
+		return time.Time{}
+	}
+	zone := time.FixedZone("", int(int8(data[16]))*15*60)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, zone)
+}
+
+// load обходит дерево директорий образа
+func (iso *ISOFS) load(volume *isoVolume) error {
+	type pending struct {
+		name   string
+		record isoRecord
+	}
+	queue := []pending{{name: "", record: volume.root}}
+	visited := make(map[int64]bool)
+
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if visited[dir.record.extent] {
+			continue
+		}
+		visited[dir.record.extent] = true
+		if len(visited) > isoMaxDirectories {
+			return fmt.Errorf("слишком много директорий в образе")
+		}
+
+		records, err := iso.readDir(dir.record, volume.joliet)
+		if err != nil {
+			return fmt.Errorf("%s: %v", dir.name, err)
+		}
+
+		var last *isoEntry
+		for _, record := range records {
+			if record.isDot {
+				continue
+			}
+
+			var rr rockRidge
+			if volume.rockRidge {
+				rr = iso.parseRockRidge(record.system, volume.suspSkip)
+				// Перемещённая глубокая директория показывается по ссылке CL у её родителя
+				if rr.relocated {
+					continue
+				}
+				if rr.childLink >= 0 {
+					if child, err := iso.relocatedDir(rr.childLink); err == nil {
+						record.extent = child.extent
+						record.size = child.size
+						record.flags |= isoFlagDir
+					}
+				}
+			}
+
+			name := record.name
+			if rr.name != "" {
+				name = rr.name
+			}
+			if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+				continue
+			}
+			fullName := path.Join(dir.name, name)
+
+			// Продолжение многосегментного файла: добавляется к предыдущей записи
+			if last != nil && last.info.Name() == name && !last.info.IsDir() {
+				last.extents = append(last.extents, isoExtent{offset: record.extent * isoSectorSize, size: record.size})
+				info := last.info.(*fileInfo)
+				info.size += record.size
+				if record.flags&isoFlagMultiExtent == 0 {
+					last = nil
+				}
+				continue
+			}
+
+			info := &fileInfo{name: name, modTime: record.modTime, mode: 0444, size: record.size}
+			if record.flags&isoFlagDir != 0 {
+				info.mode = os.ModeDir | 0555
+				info.size = 0
+			}
+			if rr.hasMode {
+				info.mode = rr.mode
+				if info.mode.IsDir() {
+					info.size = 0
+				}
+				if record.flags&isoFlagDir != 0 && !info.mode.IsDir() {
+					info.mode = os.ModeDir | info.mode.Perm()
+				}
+			}
+			if !rr.modTime.IsZero() {
+				info.modTime = rr.modTime
+			}
+
+			entry := &isoEntry{info: info, linkname: rr.linkname}
+			if info.Mode()&os.ModeSymlink != 0 {
+				info.size = int64(len(rr.linkname))
+			} else if !info.IsDir() {
+				entry.extents = []isoExtent{{offset: record.extent * isoSectorSize, size: record.size}}
+			}
+			iso.entries[fullName] = entry
+			iso.tree.add(fullName, info)
+
+			last = nil
+			if record.flags&isoFlagMultiExtent != 0 && !info.IsDir() {
+				last = entry
+			}
+			if info.IsDir() {
+				queue = append(queue, pending{name: fullName, record: record})
+			}
+		}
+	}
+	return nil
+}
+
+// readDir читает все записи директории. Записи не пересекают границу сектора:
+// нулевая длина означает переход к следующему сектору.
+func (iso *ISOFS) readDir(dir isoRecord, joliet bool) ([]isoRecord, error) {
+	// Длина из образа не проверена: директория за концом образа — повреждение
+	offset := dir.extent * isoSectorSize
+	if offset > iso.size || dir.size > iso.size-offset {
+		return nil, fmt.Errorf("директория выходит за пределы образа")
+	}
+	data := make([]byte, dir.size)
+	if _, err := iso.ra.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	var records []isoRecord
+	for pos := 0; pos < len(data); {
+		if data[pos] == 0 {
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		record, err := parseISORecord(data[pos:], joliet)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		pos += int(data[pos])
+	}
+	return records, nil
+}
+
+// relocatedDir читает запись "." перемещённой директории
+func (iso *ISOFS) relocatedDir(extent int64) (isoRecord, error) {
+	sector := make([]byte, isoSectorSize)
+	if _, err := iso.ra.ReadAt(sector, extent*isoSectorSize); err != nil {
+		return isoRecord{}, err
+	}
+	return parseISORecord(sector, false)
+}
+
+// ===================== Rock Ridge =====================
+
+// rockRidge — атрибуты POSIX из записей SUSP
+type rockRidge struct {
+	name      string
+	mode      os.FileMode
+	hasMode   bool
+	modTime   time.Time
+	linkname  string
+	childLink int64
+	relocated bool
+}
+
+// parseRockRidge разбирает записи NM, PX, SL, TF, CL, RE и продолжения CE
+func (iso *ISOFS) parseRockRidge(system []byte, skip int) rockRidge {
+	rr := rockRidge{childLink: -1}
+	if skip < len(system) {
+		system = system[skip:]
+	} else {
+		system = nil
+	}
+
+	var link []string
+	linkComponent := ""
+	linkContinues := false
+
+	for areas := 0; len(system) >= 4 && areas < 32; areas++ {
+		var next []byte
+		for len(system) >= 4 {
+			sig, length := string(system[:2]), int(system[2])
+			if length < 4 || length > len(system) {
+				break
+			}
+			entry := system[4:length]
+			system = system[length:]
+
+			switch sig {
+			case "NM":
+				if len(entry) >= 1 && entry[0]&0x06 == 0 {
+					rr.name += string(entry[1:])
+				}
+			case "PX":
+				if len(entry) >= 4 {
+					rr.mode = cpioMode(int64(binary.LittleEndian.Uint32(entry[0:4])))
+					rr.hasMode = true
+				}
+			case "TF":
+				rr.modTime = parseRockRidgeTime(entry, rr.modTime)
+			case "SL":
+				if len(entry) < 1 {
+					continue
+				}
+				for components := entry[1:]; len(components) >= 2; {
+					flags, clen := components[0], int(components[1])
+					if 2+clen > len(components) {
+						break
+					}
+					var part string
+					switch {
+					case flags&0x02 != 0:
+						part = "."
+					case flags&0x04 != 0:
+						part = ".."
+					case flags&0x08 != 0:
+						part = "/"
+					default:
+						part = string(components[2 : 2+clen])
+					}
+					components = components[2+clen:]
+
+					linkComponent += part
+					if flags&0x01 != 0 {
+						linkContinues = true
+						continue
+					}
+					link = append(link, linkComponent)
+					linkComponent = ""
+					linkContinues = false
+				}
+			case "CL":
+				if len(entry) >= 4 {
+					rr.childLink = int64(binary.LittleEndian.Uint32(entry[0:4]))
+				}
+			case "RE":
+				rr.relocated = true
+			case "CE":
+				if len(entry) >= 24 {
+					block := int64(binary.LittleEndian.Uint32(entry[0:4]))
+					offset := int64(binary.LittleEndian.Uint32(entry[8:12]))
+					size := int64(binary.LittleEndian.Uint32(entry[16:20]))
+					if size <= isoSectorSize {
+						next = make([]byte, size)
+						if _, err := iso.ra.ReadAt(next, block*isoSectorSize+offset); err != nil {
+							next = nil
+						}
+					}
+				}
+			case "ST":
+				system = nil
+			}
+		}
+		system = next
+	}
+
+	if linkContinues && linkComponent != "" {
+		link = append(link, linkComponent)
+	}
+	if len(link) > 0 {
+		if link[0] == "/" {
+			rr.linkname = "/" + strings.Join(link[1:], "/")
+		} else {
+			rr.linkname = strings.Join(link, "/")
+		}
+	}
+	return rr
+}
+
+// parseRockRidgeTime возвращает время изменения из записи TF
+func parseRockRidgeTime(entry []byte, fallback time.Time) time.Time {
+	if len(entry) < 1 {
+		return fallback
+	}
+	flags := entry[0]
+	size := 7
+	if flags&0x80 != 0 {
+		size = 17
+	}
+	pos := 1
+	if flags&0x01 != 0 { // время создания
+		pos += size
+	}
+	if flags&0x02 == 0 || pos+size > len(entry) {
+		return fallback
+	}
+	if size == 17 {
+		return isoLongTime(entry[pos : pos+size])
+	}
+	return isoRecordTime(entry[pos : pos+size])
+}
+
+// ===================== Доступ к файлам =====================
+
+func (iso *ISOFS) lookup(op, name string) (*isoEntry, error) {
+	entry, ok := iso.entries[cleanEntryName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return entry, nil
+}
+
+func (iso *ISOFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return iso.tree.readDir(name)
+}
+
+func (iso *ISOFS) Stat(name string) (os.FileInfo, error) {
+	return iso.tree.stat(name)
+}
+
+func (iso *ISOFS) Open(name string) (File, error) {
+	entry, err := iso.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.info.IsDir() {
+		return nil, fmt.Errorf("%s является директорией", name)
+	}
+
+	if len(entry.extents) == 1 {
+		extent := entry.extents[0]
+		return &sectionFile{
+			SectionReader: io.NewSectionReader(iso.ra, extent.offset, extent.size),
+			info:          entry.info,
+		}, nil
+	}
+
+	readers := make([]io.Reader, 0, len(entry.extents))
+	for _, extent := range entry.extents {
+		readers = append(readers, io.NewSectionReader(iso.ra, extent.offset, extent.size))
+	}
+	return &readOnlyFile{
+		ReadCloser: io.NopCloser(io.MultiReader(readers...)),
+		info:       entry.info,
+	}, nil
+}
+
+func (iso *ISOFS) ReadLink(name string) (string, error) {
+	entry, err := iso.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.info.Mode()&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("%s не является символической ссылкой", name)
+	}
+	return entry.linkname, nil
+}
+
+func (iso *ISOFS) archive() {}
+
+func (iso *ISOFS) Create(name string) (File, error)          { return nil, ErrReadOnly }
+func (iso *ISOFS) Mkdir(name string) error                   { return ErrReadOnly }
+func (iso *ISOFS) Rename(oldname, newname string) error      { return ErrReadOnly }
+func (iso *ISOFS) Remove(name string) error                  { return ErrReadOnly }
+func (iso *ISOFS) Chmod(name string, mode os.FileMode) error { return ErrReadOnly }
+
+func (iso *ISOFS) Close() error {
+	if iso.closer != nil {
+		return iso.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// isoRecordBytes собирает запись директории ISO 9660
+func isoRecordBytes(name string, extent, size uint32, flags byte) []byte {
+	length := 33 + len(name)
+	if length%2 != 0 {
+		length++
+	}
+	record := make([]byte, length)
+	record[0] = byte(length)
+	binary.LittleEndian.PutUint32(record[2:6], extent)
+	binary.BigEndian.PutUint32(record[6:10], extent)
+	binary.LittleEndian.PutUint32(record[10:14], size)
+	binary.BigEndian.PutUint32(record[14:18], size)
+	record[25] = flags
+	record[32] = byte(len(name))
+	copy(record[33:], name)
+	return record
+}
+
+// isoImage собирает образ с корнем в секторе 18 и поддиректорией SUB,
+// запись которой указывает на extent и size
+func isoImage(extent, size uint32) []byte {
+	img := make([]byte, 20*isoSectorSize)
+
+	pvd := img[isoFirstVolume*isoSectorSize:]
+	pvd[0] = 1
+	copy(pvd[1:6], "CD001")
+	copy(pvd[156:190], isoRecordBytes("\x00", 18, isoSectorSize, isoFlagDir))
+
+	terminator := img[(isoFirstVolume+1)*isoSectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:6], "CD001")
+
+	var root []byte
+	root = append(root, isoRecordBytes("\x00", 18, isoSectorSize, isoFlagDir)...)
+	root = append(root, isoRecordBytes("\x01", 18, isoSectorSize, isoFlagDir)...)
+	root = append(root, isoRecordBytes("SUB", extent, size, isoFlagDir)...)
+	copy(img[18*isoSectorSize:], root)
+
+	var sub []byte
+	sub = append(sub, isoRecordBytes("\x00", 19, isoSectorSize, isoFlagDir)...)
+	sub = append(sub, isoRecordBytes("\x01", 18, isoSectorSize, isoFlagDir)...)
+	copy(img[19*isoSectorSize:], sub)
+	return img
+}
+
+func TestISODirectoryBounds(t *testing.T) {
+	img := isoImage(19, isoSectorSize)
+	iso, err := NewISOFS(bytes.NewReader(img), int64(len(img)))
+	if err != nil {
+		t.Fatalf("NewISOFS: %v", err)
+	}
+	if info, err := iso.Stat("SUB"); err != nil || !info.IsDir() {
+		t.Fatalf("SUB не найдена: %v", err)
+	}
+
+	corrupt := map[string][]byte{
+		"огромный размер директории":  isoImage(19, 0xFFFFFFF0),
+		"директория за концом образа": isoImage(0xFFFFFF, isoSectorSize),
+		"хвост за концом образа":      isoImage(19, 2*isoSectorSize),
+	}
+	for name, img := range corrupt {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewISOFS(bytes.NewReader(img), int64(len(img))); err == nil {
+				t.Fatal("ожидалась ошибка для повреждённого образа")
+			}
+		})
+	}
+}
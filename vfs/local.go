@@ -0,0 +1,75 @@
+package vfs
+
+import (
+	"os"
+	"time"
+)
+
+// ===================== Локальная файловая система =====================
+
+// LocalFS работает с файлами на локальном диске
+type LocalFS struct{}
+
+func NewLocalFS() *LocalFS {
+	return &LocalFS{}
+}
+
+func (l *LocalFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (l *LocalFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (l *LocalFS) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+func (l *LocalFS) Create(name string) (File, error) {
+	return os.Create(name)
+}
+
+func (l *LocalFS) OpenAppend(name string) (File, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+}
+
+func (l *LocalFS) Mkdir(name string) error {
+	return os.Mkdir(name, 0755)
+}
+
+func (l *LocalFS) Rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
+
+func (l *LocalFS) Remove(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (l *LocalFS) ReadLink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+func (l *LocalFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (l *LocalFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (l *LocalFS) Close() error {
+	return nil
+}
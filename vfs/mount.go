@@ -0,0 +1,137 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ===================== Составные файловые системы =====================
+
+// MountFS собирает виртуальную директорию из файлов в памяти и вложенных
+// файловых систем. Используется для пакетов и образов, где содержимое
+// состоит из нескольких архивов и служебных файлов.
+type MountFS struct {
+	files   map[string]*fileInfo
+	data    map[string][]byte
+	mounts  map[string]FileSystem
+	modTime time.Time
+	closers []io.Closer
+}
+
+func newMountFS(modTime time.Time) *MountFS {
+	return &MountFS{
+		files:   make(map[string]*fileInfo),
+		data:    make(map[string][]byte),
+		mounts:  make(map[string]FileSystem),
+		modTime: modTime,
+	}
+}
+
+// addFile добавляет файл с содержимым в памяти
+func (m *MountFS) addFile(name string, data []byte, modTime time.Time) {
+	m.files[name] = &fileInfo{name: name, size: int64(len(data)), mode: 0444, modTime: modTime}
+	m.data[name] = data
+}
+
+// mount подключает файловую систему как директорию name
+func (m *MountFS) mount(name string, fsys FileSystem) {
+	m.mounts[name] = fsys
+}
+
+// resolve разбирает путь на имя верхнего уровня и остаток внутри него
+func (m *MountFS) resolve(name string) (string, string) {
+	name = cleanEntryName(name)
+	top, rest, _ := strings.Cut(name, "/")
+	return top, rest
+}
+
+func (m *MountFS) notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (m *MountFS) ReadDir(name string) ([]os.FileInfo, error) {
+	top, rest := m.resolve(name)
+	if top == "" {
+		var infos []os.FileInfo
+		for _, info := range m.files {
+			infos = append(infos, info)
+		}
+		for mountName := range m.mounts {
+			infos = append(infos, &fileInfo{name: mountName, mode: os.ModeDir | 0555, modTime: m.modTime})
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+		return infos, nil
+	}
+	if fsys, ok := m.mounts[top]; ok {
+		return fsys.ReadDir(rest)
+	}
+	return nil, m.notExist("readdir", name)
+}
+
+func (m *MountFS) Stat(name string) (os.FileInfo, error) {
+	top, rest := m.resolve(name)
+	if top == "" {
+		return &fileInfo{name: "/", mode: os.ModeDir | 0555, modTime: m.modTime}, nil
+	}
+	if fsys, ok := m.mounts[top]; ok {
+		if rest == "" {
+			return &fileInfo{name: top, mode: os.ModeDir | 0555, modTime: m.modTime}, nil
+		}
+		return fsys.Stat(rest)
+	}
+	if info, ok := m.files[top]; ok && rest == "" {
+		return info, nil
+	}
+	return nil, m.notExist("stat", name)
+}
+
+func (m *MountFS) Open(name string) (File, error) {
+	top, rest := m.resolve(name)
+	if fsys, ok := m.mounts[top]; ok && rest != "" {
+		return fsys.Open(rest)
+	}
+	if info, ok := m.files[top]; ok && rest == "" {
+		return &memFile{Reader: bytes.NewReader(m.data[top]), info: info}, nil
+	}
+	return nil, m.notExist("open", name)
+}
+
+func (m *MountFS) ReadLink(name string) (string, error) {
+	top, rest := m.resolve(name)
+	if fsys, ok := m.mounts[top]; ok && rest != "" {
+		return fsys.ReadLink(rest)
+	}
+	return "", m.notExist("readlink", name)
+}
+
+func (m *MountFS) archive() {}
+
+func (m *MountFS) Create(name string) (File, error)          { return nil, ErrReadOnly }
+func (m *MountFS) Mkdir(name string) error                   { return ErrReadOnly }
+func (m *MountFS) Rename(oldname, newname string) error      { return ErrReadOnly }
+func (m *MountFS) Remove(name string) error                  { return ErrReadOnly }
+func (m *MountFS) Chmod(name string, mode os.FileMode) error { return ErrReadOnly }
+
+func (m *MountFS) Close() error {
+	for _, fsys := range m.mounts {
+		fsys.Close()
+	}
+	for _, c := range m.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// memFile — файл с содержимым в памяти
+type memFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (f *memFile) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (f *memFile) Stat() (os.FileInfo, error)  { return f.info, nil }
+func (f *memFile) Close() error                { return nil }
@@ -0,0 +1,53 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ===================== Потоковая запись =====================
+
+// pipeFile передаёт записанные данные в фоновую выгрузку на сервер.
+// Close закрывает поток и дожидается результата выгрузки.
+type pipeFile struct {
+	*io.PipeWriter
+	info os.FileInfo
+	done chan error
+	once sync.Once
+	err  error
+}
+
+// newPipeFile запускает upload, который читает записываемые в файл данные
+func newPipeFile(name string, upload func(r io.Reader) error) *pipeFile {
+	pr, pw := io.Pipe()
+	file := &pipeFile{
+		PipeWriter: pw,
+		info:       &fileInfo{name: path.Base(filepath.ToSlash(name)), mode: 0644, modTime: time.Now()},
+		done:       make(chan error, 1),
+	}
+	go func() {
+		err := upload(pr)
+		// Если сервер прервал приём, пишущая сторона получит ошибку вместо блокировки
+		pr.CloseWithError(err)
+		file.done <- err
+	}()
+	return file
+}
+
+func (w *pipeFile) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("файл открыт только для записи")
+}
+func (w *pipeFile) Stat() (os.FileInfo, error) { return w.info, nil }
+
+func (w *pipeFile) Close() error {
+	w.once.Do(func() {
+		w.PipeWriter.Close()
+		w.err = <-w.done
+	})
+	return w.err
+}
@@ -0,0 +1,209 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ===================== Пакеты RPM =====================
+
+var (
+	rpmLeadMagic   = []byte{0xed, 0xab, 0xee, 0xdb}
+	rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+)
+
+const rpmLeadSize = 96
+
+// Теги заголовка RPM, которые показываются в метаданных
+const (
+	rpmTagName        = 1000
+	rpmTagVersion     = 1001
+	rpmTagRelease     = 1002
+	rpmTagEpoch       = 1003
+	rpmTagSummary     = 1004
+	rpmTagDescription = 1005
+	rpmTagBuildTime   = 1006
+	rpmTagBuildHost   = 1007
+	rpmTagSize        = 1009
+	rpmTagVendor      = 1011
+	rpmTagLicense     = 1014
+	rpmTagPackager    = 1015
+	rpmTagGroup       = 1016
+	rpmTagURL         = 1020
+	rpmTagArch        = 1022
+	rpmTagSourceRPM   = 1044
+	rpmTagProvideName = 1047
+	rpmTagRequireName = 1049
+)
+
+// rpmHeader — разобранный заголовок RPM: значения тегов строками
+type rpmHeader map[int][]string
+
+// readRPMHeader читает структуру заголовка по смещению offset и возвращает её размер
+func readRPMHeader(ra io.ReaderAt, offset int64) (rpmHeader, int64, error) {
+	intro := make([]byte, 16)
+	if _, err := ra.ReadAt(intro, offset); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(intro[:4], rpmHeaderMagic) {
+		return nil, 0, errors.New("повреждённый заголовок RPM")
+	}
+	count := int64(binary.BigEndian.Uint32(intro[8:12]))
+	storeSize := int64(binary.BigEndian.Uint32(intro[12:16]))
+	if count > 1<<16 || storeSize > 1<<28 {
+		return nil, 0, errors.New("слишком большой заголовок RPM")
+	}
+
+	data := make([]byte, count*16+storeSize)
+	if _, err := ra.ReadAt(data, offset+16); err != nil {
+		return nil, 0, err
+	}
+	index, store := data[:count*16], data[count*16:]
+
+	header := make(rpmHeader)
+	for i := int64(0); i < count; i++ {
+		entry := index[i*16 : i*16+16]
+		tag := int(binary.BigEndian.Uint32(entry[0:4]))
+		typ := binary.BigEndian.Uint32(entry[4:8])
+		off := int64(binary.BigEndian.Uint32(entry[8:12]))
+		n := int(binary.BigEndian.Uint32(entry[12:16]))
+		if off >= storeSize {
+			continue
+		}
+		header[tag] = rpmValues(store[off:], typ, n)
+	}
+	return header, 16 + count*16 + storeSize, nil
+}
+
+// rpmValues декодирует значение тега в строки
+func rpmValues(data []byte, typ uint32, count int) []string {
+	var values []string
+	switch typ {
+	case 4: // INT32
+		for i := 0; i < count && len(data) >= 4*(i+1); i++ {
+			values = append(values, fmt.Sprint(binary.BigEndian.Uint32(data[4*i:])))
+		}
+	case 6, 8, 9: // STRING, STRING_ARRAY, I18NSTRING
+		if typ == 6 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				break
+			}
+			values = append(values, string(data[:end]))
+			data = data[end+1:]
+		}
+	}
+	return values
+}
+
+// first возвращает первое значение тега
+func (h rpmHeader) first(tag int) string {
+	if values := h[tag]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// metadata формирует текстовое описание пакета
+func (h rpmHeader) metadata() string {
+	var sb strings.Builder
+	fields := []struct {
+		title string
+		tag   int
+	}{
+		{"Name", rpmTagName}, {"Epoch", rpmTagEpoch}, {"Version", rpmTagVersion},
+		{"Release", rpmTagRelease}, {"Architecture", rpmTagArch}, {"Summary", rpmTagSummary},
+		{"License", rpmTagLicense}, {"Group", rpmTagGroup}, {"URL", rpmTagURL},
+		{"Vendor", rpmTagVendor}, {"Packager", rpmTagPackager}, {"Build Host", rpmTagBuildHost},
+		{"Size", rpmTagSize}, {"Source RPM", rpmTagSourceRPM},
+	}
+	for _, f := range fields {
+		if value := h.first(f.tag); value != "" {
+			fmt.Fprintf(&sb, "%-14s %s\n", f.title+":", value)
+		}
+	}
+	if buildTime := h.first(rpmTagBuildTime); buildTime != "" {
+		var sec int64
+		fmt.Sscan(buildTime, &sec)
+		fmt.Fprintf(&sb, "%-14s %s\n", "Build Date:", time.Unix(sec, 0).UTC().Format(time.RFC1123))
+	}
+	if description := h.first(rpmTagDescription); description != "" {
+		fmt.Fprintf(&sb, "\nDescription:\n%s\n", description)
+	}
+	for _, list := range []struct {
+		title string
+		tag   int
+	}{{"Provides", rpmTagProvideName}, {"Requires", rpmTagRequireName}} {
+		if values := h[list.tag]; len(values) > 0 {
+			fmt.Fprintf(&sb, "\n%s:\n", list.title)
+			for _, v := range values {
+				fmt.Fprintf(&sb, "  %s\n", v)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// OpenRPM открывает пакет .rpm: метаданные заголовка показываются файлом
+// metadata.txt, а содержимое cpio-нагрузки — директорией payload
+func OpenRPM(fsys FileSystem, name string) (*MountFS, error) {
+	ra, size, closer, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := newRPMFS(ra, size)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	pkg.closers = append(pkg.closers, closer)
+	return pkg, nil
+}
+
+func newRPMFS(ra io.ReaderAt, size int64) (*MountFS, error) {
+	lead := make([]byte, rpmLeadSize)
+	if _, err := ra.ReadAt(lead, 0); err != nil || !bytes.Equal(lead[:4], rpmLeadMagic) {
+		return nil, errors.New("файл не является пакетом RPM")
+	}
+
+	// Заголовок подписи выравнивается до 8 байт
+	_, sigSize, err := readRPMHeader(ra, rpmLeadSize)
+	if err != nil {
+		return nil, err
+	}
+	offset := rpmLeadSize + sigSize
+	offset += (8 - offset%8) % 8
+
+	header, headerSize, err := readRPMHeader(ra, offset)
+	if err != nil {
+		return nil, err
+	}
+	payloadOffset := offset + headerSize
+
+	var buildTime int64
+	fmt.Sscan(header.first(rpmTagBuildTime), &buildTime)
+	modTime := time.Unix(buildTime, 0)
+
+	pkg := newMountFS(modTime)
+	pkg.addFile("metadata.txt", []byte(header.metadata()), modTime)
+
+	magic := make([]byte, 6)
+	if _, err := ra.ReadAt(magic, payloadOffset); err != nil {
+		return nil, fmt.Errorf("ошибка чтения нагрузки RPM: %v", err)
+	}
+	payload, err := NewCpioFS(sectionOpener(ra, payloadOffset, size-payloadOffset), DetectCompression(magic))
+	if err != nil {
+		return nil, fmt.Errorf("нагрузка RPM: %v", err)
+	}
+	pkg.mount("payload", payload)
+	return pkg, nil
+}
@@ -0,0 +1,403 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ===================== S3-совместимые хранилища =====================
+
+// Размер части при потоковой загрузке объекта неизвестного размера
+const s3PartSize = 16 * 1024 * 1024
+
+// s3PutOptions отключают потоковую подпись (aws-chunked), которую minio-go включает
+// для HTTP: часть совместимых серверов сохраняет разметку чанков вместе с данными
+// или требует Content-Length
+var s3PutOptions = minio.PutObjectOptions{PartSize: s3PartSize, DisableContentSha256: true}
+
+// S3Config описывает подключение к S3-совместимому хранилищу
+type S3Config struct {
+	Endpoint  string // host[:port] без схемы
+	Region    string
+	AccessKey string
+	SecretKey string
+	Secure    bool // HTTPS
+}
+
+// S3FS показывает хранилище как файловую систему: корень содержит бакеты,
+// префиксы ключей с разделителем "/" выглядят как директории. Объекты читаются
+// диапазонными запросами GET, поэтому поддерживают произвольный доступ.
+type S3FS struct {
+	client *minio.Client
+	region string
+}
+
+// NewS3FS подключается к хранилищу и проверяет ключи доступа
+func NewS3FS(config S3Config) (*S3FS, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.Secure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &S3FS{client: client, region: config.Region}
+	if _, err := client.ListBuckets(context.Background()); err != nil {
+		return nil, s3Error("list", "/", err)
+	}
+	return s, nil
+}
+
+// split разбирает путь на имя бакета и ключ объекта
+func (s *S3FS) split(name string) (string, string) {
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	bucket, key, _ := strings.Cut(name, "/")
+	return bucket, key
+}
+
+// s3Error переводит отсутствующие бакеты и ключи в os.ErrNotExist
+func s3Error(op, name string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket", "NotFound":
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case "AccessDenied", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return fmt.Errorf("%s %s: доступ запрещён: %v", op, name, err)
+	}
+	return fmt.Errorf("%s %s: %v", op, name, err)
+}
+
+func dirInfo(name string, modTime time.Time) *fileInfo {
+	return &fileInfo{name: name, mode: os.ModeDir | 0755, modTime: modTime}
+}
+
+func objectInfo(name string, object minio.ObjectInfo) *fileInfo {
+	return &fileInfo{name: name, size: object.Size, mode: 0644, modTime: object.LastModified}
+}
+
+func (s *S3FS) ReadDir(name string) ([]os.FileInfo, error) {
+	ctx := context.Background()
+	bucket, key := s.split(name)
+
+	if bucket == "" {
+		buckets, err := s.client.ListBuckets(ctx)
+		if err != nil {
+			return nil, s3Error("readdir", name, err)
+		}
+		infos := make([]os.FileInfo, 0, len(buckets))
+		for _, b := range buckets {
+			infos = append(infos, dirInfo(b.Name, b.CreationDate))
+		}
+		return infos, nil
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	var infos []os.FileInfo
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, s3Error("readdir", name, object.Err)
+		}
+		child := strings.TrimPrefix(object.Key, prefix)
+		// Объект-маркер самой директории ("dir/") не показываем
+		if child == "" {
+			continue
+		}
+		if strings.HasSuffix(child, "/") {
+			infos = append(infos, dirInfo(strings.TrimSuffix(child, "/"), object.LastModified))
+			continue
+		}
+		infos = append(infos, objectInfo(child, object))
+	}
+
+	// Пустой список у несуществующего префикса отличаем от пустой директории
+	if len(infos) == 0 && key != "" {
+		if _, err := s.Stat(name); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func (s *S3FS) Stat(name string) (os.FileInfo, error) {
+	ctx := context.Background()
+	bucket, key := s.split(name)
+
+	if bucket == "" {
+		return dirInfo("/", time.Time{}), nil
+	}
+	if key == "" {
+		exists, err := s.client.BucketExists(ctx, bucket)
+		if err != nil {
+			return nil, s3Error("stat", name, err)
+		}
+		if !exists {
+			return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+		}
+		return dirInfo(bucket, time.Time{}), nil
+	}
+
+	object, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return objectInfo(path.Base(key), object), nil
+	}
+	if !os.IsNotExist(s3Error("stat", name, err)) {
+		return nil, s3Error("stat", name, err)
+	}
+
+	// Ключа нет — возможно, это префикс с объектами внутри
+	listCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for object := range s.client.ListObjects(listCtx, bucket, minio.ListObjectsOptions{Prefix: key + "/", MaxKeys: 1}) {
+		if object.Err != nil {
+			return nil, s3Error("stat", name, object.Err)
+		}
+		return dirInfo(path.Base(key), time.Time{}), nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (s *S3FS) Open(name string) (File, error) {
+	return s.open(name, -1)
+}
+
+// OpenHead запрашивает только первые limit байт объекта, чтобы превью большого
+// объекта не скачивало его целиком. Последовательное чтение такого файла
+// заканчивается на limit байтах; ReadAt по-прежнему видит весь объект.
+func (s *S3FS) OpenHead(name string, limit int64) (File, error) {
+	return s.open(name, limit)
+}
+
+// open открывает объект; при limit >= 0 запрос GET ограничивается диапазоном
+func (s *S3FS) open(name string, limit int64) (File, error) {
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s является директорией", name)
+	}
+
+	bucket, key := s.split(name)
+	opts := minio.GetObjectOptions{}
+	if limit > 0 && limit < info.Size() {
+		if err := opts.SetRange(0, limit-1); err != nil {
+			return nil, err
+		}
+	}
+	object, err := s.client.GetObject(context.Background(), bucket, key, opts)
+	if err != nil {
+		return nil, s3Error("open", name, err)
+	}
+	return &s3File{Object: object, client: s.client, bucket: bucket, key: key, info: info}, nil
+}
+
+// s3File — объект хранилища; Read и Seek выполняют диапазонные запросы GET
+type s3File struct {
+	*minio.Object
+	client      *minio.Client
+	bucket, key string
+	info        os.FileInfo
+}
+
+// ReadAt читает диапазон отдельным запросом: minio.Object.ReadAt сдвигает
+// позицию последовательного чтения, а io.ReaderAt этого не допускает
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off >= f.info.Size() {
+		return 0, io.EOF
+	}
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(off, off+int64(len(p))-1); err != nil {
+		return 0, err
+	}
+	object, err := f.client.GetObject(context.Background(), f.bucket, f.key, opts)
+	if err != nil {
+		return 0, s3Error("read", f.key, err)
+	}
+	defer object.Close()
+
+	n, err := io.ReadFull(object, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *s3File) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (f *s3File) Stat() (os.FileInfo, error)  { return f.info, nil }
+
+func (s *S3FS) Create(name string) (File, error) {
+	bucket, key := s.split(name)
+	if key == "" {
+		return nil, fmt.Errorf("файлы можно создавать только внутри бакета")
+	}
+	return newPipeFile(name, func(r io.Reader) error {
+		_, err := s.client.PutObject(context.Background(), bucket, key, r, -1, s3PutOptions)
+		if err != nil {
+			return s3Error("create", name, err)
+		}
+		return nil
+	}), nil
+}
+
+// Mkdir на верхнем уровне создаёт бакет, глубже — пустой объект-маркер "dir/"
+func (s *S3FS) Mkdir(name string) error {
+	ctx := context.Background()
+	bucket, key := s.split(name)
+	if bucket == "" {
+		return fmt.Errorf("не указано имя бакета")
+	}
+	if key == "" {
+		if err := s.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: s.region}); err != nil {
+			return s3Error("mkdir", name, err)
+		}
+		return nil
+	}
+	_, err := s.client.PutObject(ctx, bucket, key+"/", strings.NewReader(""), 0, s3PutOptions)
+	if err != nil {
+		return s3Error("mkdir", name, err)
+	}
+	return nil
+}
+
+// listObjects возвращает все объекты под префиксом, включая маркеры директорий
+func (s *S3FS) listObjects(ctx context.Context, bucket, prefix string) ([]minio.ObjectInfo, error) {
+	var objects []minio.ObjectInfo
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// Предельный размер объекта для CopyObject; больший копируется по частям
+const s3MaxCopySize = 5 * 1024 * 1024 * 1024
+
+// copyObject копирует объект на сервере. CopyObject не принимает объекты больше
+// 5 ГиБ, их ComposeObject копирует частями (UploadPartCopy).
+func (s *S3FS) copyObject(ctx context.Context, srcBucket, src, dstBucket, dst string, size int64) error {
+	dstOpts := minio.CopyDestOptions{Bucket: dstBucket, Object: dst}
+	srcOpts := minio.CopySrcOptions{Bucket: srcBucket, Object: src}
+	var err error
+	if size > s3MaxCopySize {
+		_, err = s.client.ComposeObject(ctx, dstOpts, srcOpts)
+	} else {
+		_, err = s.client.CopyObject(ctx, dstOpts, srcOpts)
+	}
+	return err
+}
+
+// Rename копирует объекты на сервере и удаляет исходные: в S3 нет переименования.
+// Директория переносится целиком, объект за объектом.
+func (s *S3FS) Rename(oldname, newname string) error {
+	ctx := context.Background()
+	srcBucket, srcKey := s.split(oldname)
+	dstBucket, dstKey := s.split(newname)
+	if srcKey == "" || dstKey == "" {
+		return fmt.Errorf("бакеты нельзя переименовывать")
+	}
+
+	info, err := s.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Stat(newname); err == nil {
+		return fmt.Errorf("%s уже существует", newname)
+	}
+
+	objects := []minio.ObjectInfo{{Key: srcKey, Size: info.Size()}}
+	if info.IsDir() {
+		objects, err = s.listObjects(ctx, srcBucket, srcKey+"/")
+		if err != nil {
+			return s3Error("rename", oldname, err)
+		}
+	}
+
+	for _, object := range objects {
+		src := object.Key
+		dst := dstKey + strings.TrimPrefix(src, srcKey)
+		if err := s.copyObject(ctx, srcBucket, src, dstBucket, dst, object.Size); err != nil {
+			return s3Error("rename", oldname, err)
+		}
+		if err := s.client.RemoveObject(ctx, srcBucket, src, minio.RemoveObjectOptions{}); err != nil {
+			return s3Error("rename", oldname, err)
+		}
+	}
+	return nil
+}
+
+// Remove удаляет объект, все объекты под префиксом или бакет вместе с содержимым
+func (s *S3FS) Remove(name string) error {
+	ctx := context.Background()
+	bucket, key := s.split(name)
+	if bucket == "" {
+		return fmt.Errorf("нельзя удалить корень хранилища")
+	}
+
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		if err := s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return s3Error("remove", name, err)
+		}
+		return nil
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+	listed, err := s.listObjects(ctx, bucket, prefix)
+	if err != nil {
+		return s3Error("remove", name, err)
+	}
+	objects := make(chan minio.ObjectInfo, len(listed))
+	for _, object := range listed {
+		objects <- object
+	}
+	close(objects)
+	for result := range s.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil {
+			return s3Error("remove", result.ObjectName, result.Err)
+		}
+	}
+
+	if key == "" {
+		if err := s.client.RemoveBucket(ctx, bucket); err != nil {
+			return s3Error("remove", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *S3FS) ReadLink(name string) (string, error) {
+	return "", fmt.Errorf("символические ссылки не поддерживаются в S3")
+}
+
+func (s *S3FS) Chmod(name string, mode os.FileMode) error {
+	return fmt.Errorf("изменение прав не поддерживается в S3")
+}
+
+func (s *S3FS) Close() error {
+	return nil
+}
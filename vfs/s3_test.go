@@ -0,0 +1,497 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 — минимальное S3-совместимое хранилище в памяти (адресация
+// path-style): списки бакетов и объектов, диапазонные GET, загрузка и
+// копирование частями, копирование и удаление
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string][]byte
+	uploads map[string]map[int][]byte // номер части по идентификатору загрузки
+	sizes   map[string]int64          // размер "bucket/key", который сообщает HEAD вместо настоящего
+	ranges  []string                  // заголовки Range запросов GET
+	copies  []string                  // диапазоны копирования частями (UploadPartCopy)
+	parts   int                       // принятые части загрузок
+}
+
+var fakeS3Time = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		buckets: make(map[string]map[string][]byte),
+		uploads: make(map[string]map[int][]byte),
+		sizes:   make(map[string]int64),
+	}
+}
+
+func (f *fakeS3) put(bucket, key, data string) {
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string][]byte)
+	}
+	f.buckets[bucket][key] = []byte(data)
+}
+
+func (f *fakeS3) object(bucket, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.buckets[bucket][key]
+	return data, ok
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	objects, bucketExists := f.buckets[bucket]
+
+	switch {
+	case bucket == "":
+		f.listBuckets(w)
+	case !bucketExists && !(key == "" && r.Method == http.MethodPut):
+		s3ErrorResponse(w, http.StatusNotFound, "NoSuchBucket")
+	case key == "":
+		f.serveBucket(w, r, bucket, objects)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = make(map[int][]byte)
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: id})
+	case r.Method == http.MethodPut && query.Has("uploadId") && r.Header.Get("X-Amz-Copy-Source") != "":
+		// Диапазон за концом настоящих данных копируется пустым: так объект
+		// с подменённым размером проходит через копирование частями
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, ok := f.copySource(r)
+		if !ok {
+			s3ErrorResponse(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		byteRange := strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source-Range"), "bytes=")
+		first, last, _ := strings.Cut(byteRange, "-")
+		start, _ := strconv.ParseInt(first, 10, 64)
+		end, _ := strconv.ParseInt(last, 10, 64)
+		start, end = min(start, int64(len(data))), min(end+1, int64(len(data)))
+		f.uploads[query.Get("uploadId")][number] = bytes.Clone(data[start:end])
+		f.copies = append(f.copies, byteRange)
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyPartResult"`
+			ETag         string
+			LastModified string
+		}{ETag: fmt.Sprintf(`"part%d"`, number), LastModified: fakeS3Time.Format("2006-01-02T15:04:05.000Z")})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		f.uploads[query.Get("uploadId")][number] = data
+		f.parts++
+		w.Header().Set("ETag", fmt.Sprintf(`"part%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for number := range parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, number := range numbers {
+			data = append(data, parts[number]...)
+		}
+		objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: `"complete"`})
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		data, ok := f.copySource(r)
+		if !ok {
+			s3ErrorResponse(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		objects[key] = bytes.Clone(data)
+		writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: `"copy"`, LastModified: fakeS3Time.Format("2006-01-02T15:04:05.000Z")})
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		objects[key] = data
+		w.Header().Set("ETag", `"object"`)
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := objects[key]
+		if !ok {
+			s3ErrorResponse(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if r.Method == http.MethodGet {
+			f.ranges = append(f.ranges, r.Header.Get("Range"))
+		}
+		w.Header().Set("ETag", `"object"`)
+		if size, ok := f.sizes[bucket+"/"+key]; ok && r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+			w.Header().Set("Last-Modified", fakeS3Time.Format(http.TimeFormat))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, key, fakeS3Time, bytes.NewReader(data))
+	default:
+		s3ErrorResponse(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// copySource возвращает данные объекта из заголовка X-Amz-Copy-Source
+func (f *fakeS3) copySource(r *http.Request) ([]byte, bool) {
+	source, _ := url.PathUnescape(strings.TrimPrefix(r.Header.Get("X-Amz-Copy-Source"), "/"))
+	srcBucket, srcKey, _ := strings.Cut(source, "/")
+	data, ok := f.buckets[srcBucket][srcKey]
+	return data, ok
+}
+
+func (f *fakeS3) listBuckets(w http.ResponseWriter) {
+	type bucketInfo struct {
+		Name         string
+		CreationDate string
+	}
+	var result struct {
+		XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+		Buckets []bucketInfo `xml:"Buckets>Bucket"`
+	}
+	for name := range f.buckets {
+		result.Buckets = append(result.Buckets, bucketInfo{Name: name, CreationDate: fakeS3Time.Format(time.RFC3339)})
+	}
+	writeXML(w, result)
+}
+
+func (f *fakeS3) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, objects map[string][]byte) {
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead:
+	case r.Method == http.MethodPut:
+		f.buckets[bucket] = make(map[string][]byte)
+	case r.Method == http.MethodDelete:
+		delete(f.buckets, bucket)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && query.Has("location"):
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Region  string   `xml:",chardata"`
+		}{Region: "us-east-1"})
+	case r.Method == http.MethodGet:
+		f.listObjects(w, query.Get("prefix"), query.Get("delimiter"), objects)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		var request struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		xml.NewDecoder(r.Body).Decode(&request)
+		for _, object := range request.Objects {
+			delete(objects, object.Key)
+		}
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"DeleteResult"`
+		}{})
+	default:
+		s3ErrorResponse(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// listObjects отвечает на ListObjectsV2; ключи с разделителем после префикса
+// сворачиваются в CommonPrefixes
+func (f *fakeS3) listObjects(w http.ResponseWriter, prefix, delimiter string, objects map[string][]byte) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	var result struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Prefix         string
+		Delimiter      string
+		Contents       []content
+		CommonPrefixes []commonPrefix
+	}
+	result.Prefix, result.Delimiter = prefix, delimiter
+
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 && i < len(rest)-1 {
+			dir := prefix + rest[:i+1]
+			if !seen[dir] {
+				seen[dir] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: dir})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: fakeS3Time.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         `"object"`,
+			Size:         len(objects[key]),
+		})
+	}
+	writeXML(w, result)
+}
+
+func writeXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func s3ErrorResponse(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func startS3Server(t *testing.T, fake *fakeS3) *S3FS {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	fsys, err := NewS3FS(S3Config{
+		Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3FS: %v", err)
+	}
+	return fsys
+}
+
+func TestS3ReadDirPrefixes(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "docs/a.txt", "a")
+	fake.put("bucket", "docs/sub/b.txt", "bb")
+	fake.put("bucket", "docs/empty/", "")
+	fake.put("bucket", "top.txt", "top")
+	fsys := startS3Server(t, fake)
+
+	names := func(dir string) string {
+		infos, err := fsys.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir %s: %v", dir, err)
+		}
+		var list []string
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() {
+				name += "/"
+			}
+			list = append(list, name)
+		}
+		sort.Strings(list)
+		return strings.Join(list, " ")
+	}
+	if got := names("/"); got != "bucket/" {
+		t.Fatalf("корень: %s", got)
+	}
+	if got := names("/bucket"); got != "docs/ top.txt" {
+		t.Fatalf("бакет: %s", got)
+	}
+	if got := names("/bucket/docs"); got != "a.txt empty/ sub/" {
+		t.Fatalf("префикс: %s", got)
+	}
+
+	if info, err := fsys.Stat("/bucket/docs/sub"); err != nil || !info.IsDir() {
+		t.Fatalf("префикс без маркера должен быть директорией: %v, %v", info, err)
+	}
+	if _, err := fsys.Stat("/bucket/missing"); !os.IsNotExist(err) {
+		t.Fatalf("ожидалась os.ErrNotExist, получено %v", err)
+	}
+	if _, err := fsys.ReadDir("/bucket/missing"); !os.IsNotExist(err) {
+		t.Fatalf("ожидалась os.ErrNotExist для пустого префикса, получено %v", err)
+	}
+}
+
+func TestS3RangedRead(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "data.bin", "0123456789abcdef")
+	fsys := startS3Server(t, fake)
+
+	file, err := fsys.Open("/bucket/data.bin")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	ra, ok := file.(io.ReaderAt)
+	if !ok {
+		t.Fatal("объект S3 не поддерживает ReadAt")
+	}
+	buf := make([]byte, 4)
+	if n, err := ra.ReadAt(buf, 10); err != nil || string(buf[:n]) != "abcd" {
+		t.Fatalf("ReadAt: %q, %v", buf[:n], err)
+	}
+	if n, err := ra.ReadAt(buf, 14); err != io.EOF || string(buf[:n]) != "ef" {
+		t.Fatalf("ReadAt у конца: %q, %v", buf[:n], err)
+	}
+
+	fake.mu.Lock()
+	ranges := strings.Join(fake.ranges, ",")
+	fake.mu.Unlock()
+	if !strings.Contains(ranges, "bytes=10-13") || !strings.Contains(ranges, "bytes=14-17") {
+		t.Fatalf("ожидались диапазонные запросы, получено %q", ranges)
+	}
+}
+
+func TestS3CreateMultipart(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "keep", "")
+	fsys := startS3Server(t, fake)
+
+	data := bytes.Repeat([]byte("x"), s3PartSize+10)
+	w, err := fsys.Create("/bucket/big.bin")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	stored, ok := fake.object("bucket", "big.bin")
+	if !ok || !bytes.Equal(stored, data) {
+		t.Fatalf("объект не сохранён целиком: %d байт", len(stored))
+	}
+	fake.mu.Lock()
+	parts := fake.parts
+	fake.mu.Unlock()
+	if parts != 2 {
+		t.Fatalf("ожидалось 2 части, загружено %d", parts)
+	}
+
+	if _, err := fsys.Create("/top-level"); err == nil {
+		t.Fatal("файл вне бакета не должен создаваться")
+	}
+}
+
+func TestS3Rename(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "file.txt", "content")
+	fake.put("bucket", "dir/a", "a")
+	fake.put("bucket", "dir/sub/b", "b")
+	fake.put("bucket", "taken", "")
+	fsys := startS3Server(t, fake)
+
+	if err := fsys.Rename("/bucket/file.txt", "/bucket/moved.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, ok := fake.object("bucket", "moved.txt"); !ok || string(data) != "content" {
+		t.Fatalf("объект не скопирован: %q", data)
+	}
+	if _, ok := fake.object("bucket", "file.txt"); ok {
+		t.Fatal("исходный объект не удалён")
+	}
+
+	if err := fsys.Rename("/bucket/dir", "/bucket/renamed"); err != nil {
+		t.Fatalf("Rename dir: %v", err)
+	}
+	for _, key := range []string{"renamed/a", "renamed/sub/b"} {
+		if _, ok := fake.object("bucket", key); !ok {
+			t.Fatalf("нет %s после переноса директории", key)
+		}
+	}
+	if _, ok := fake.object("bucket", "dir/a"); ok {
+		t.Fatal("исходная директория не удалена")
+	}
+
+	if err := fsys.Rename("/bucket/moved.txt", "/bucket/taken"); err == nil {
+		t.Fatal("переименование поверх существующего объекта должно завершаться ошибкой")
+	}
+	if err := fsys.Rename("/bucket", "/other"); err == nil {
+		t.Fatal("бакеты нельзя переименовывать")
+	}
+}
+
+func TestS3RenameLargeObject(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "disk.img", "image data")
+	// HEAD сообщает 6 ГиБ: CopyObject такой объект не скопирует
+	fake.sizes["bucket/disk.img"] = 6 << 30
+	fsys := startS3Server(t, fake)
+
+	if err := fsys.Rename("/bucket/disk.img", "/bucket/moved.img"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if data, ok := fake.object("bucket", "moved.img"); !ok || string(data) != "image data" {
+		t.Fatalf("объект не скопирован: %q", data)
+	}
+	if _, ok := fake.object("bucket", "disk.img"); ok {
+		t.Fatal("исходный объект не удалён")
+	}
+
+	fake.mu.Lock()
+	copies := len(fake.copies)
+	fake.mu.Unlock()
+	if copies < 2 {
+		t.Fatalf("ожидалось копирование частями, частей: %d", copies)
+	}
+}
+
+func TestS3OpenHead(t *testing.T) {
+	fake := newFakeS3()
+	fake.put("bucket", "big.log", strings.Repeat("x", 100))
+	fsys := startS3Server(t, fake)
+
+	file, err := OpenHead(fsys, "/bucket/big.log", 10)
+	if err != nil {
+		t.Fatalf("OpenHead: %v", err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || len(data) != 10 {
+		t.Fatalf("прочитано %d байт, %v", len(data), err)
+	}
+
+	fake.mu.Lock()
+	ranges := strings.Join(fake.ranges, ",")
+	fake.mu.Unlock()
+	if ranges != "bytes=0-9" {
+		t.Fatalf("ожидался запрос первых 10 байт, получено %q", ranges)
+	}
+}
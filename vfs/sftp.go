@@ -0,0 +1,87 @@
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// ===================== Файловая система SFTP =====================
+
+// SFTPFS работает с файлами на удалённом сервере через SFTP
+type SFTPFS struct {
+	client *sftp.Client
+}
+
+func NewSFTPFS(client *sftp.Client) *SFTPFS {
+	return &SFTPFS{client: client}
+}
+
+func (s *SFTPFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return s.client.ReadDir(filepath.ToSlash(name))
+}
+
+func (s *SFTPFS) Stat(name string) (os.FileInfo, error) {
+	return s.client.Stat(filepath.ToSlash(name))
+}
+
+func (s *SFTPFS) Open(name string) (File, error) {
+	return s.client.Open(filepath.ToSlash(name))
+}
+
+func (s *SFTPFS) Create(name string) (File, error) {
+	return s.client.Create(filepath.ToSlash(name))
+}
+
+// OpenAppend не использует флаг O_APPEND: не все серверы поддерживают его,
+// поэтому запись начинается со смещения, равного текущему размеру файла
+func (s *SFTPFS) OpenAppend(name string) (File, error) {
+	f, err := s.client.OpenFile(filepath.ToSlash(name), os.O_WRONLY)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = f.Seek(info.Size(), io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *SFTPFS) Mkdir(name string) error {
+	return s.client.Mkdir(filepath.ToSlash(name))
+}
+
+// Rename по возможности использует posix-rename, чтобы, как и локально, заменять существующий файл
+func (s *SFTPFS) Rename(oldname, newname string) error {
+	if _, ok := s.client.HasExtension("posix-rename@openssh.com"); ok {
+		return s.client.PosixRename(filepath.ToSlash(oldname), filepath.ToSlash(newname))
+	}
+	return s.client.Rename(filepath.ToSlash(oldname), filepath.ToSlash(newname))
+}
+
+func (s *SFTPFS) Remove(name string) error {
+	return s.client.RemoveAll(filepath.ToSlash(name))
+}
+
+func (s *SFTPFS) ReadLink(name string) (string, error) {
+	return s.client.ReadLink(filepath.ToSlash(name))
+}
+
+func (s *SFTPFS) Chmod(name string, mode os.FileMode) error {
+	return s.client.Chmod(filepath.ToSlash(name), mode)
+}
+
+func (s *SFTPFS) Chtimes(name string, atime, mtime time.Time) error {
+	return s.client.Chtimes(filepath.ToSlash(name), atime, mtime)
+}
+
+func (s *SFTPFS) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,214 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ===================== Потоковые архивы =====================
+
+// streamEntry описывает одну запись потокового архива (tar, cpio)
+type streamEntry struct {
+	name     string
+	info     os.FileInfo
+	linkname string
+	hardlink bool
+	index    int
+	offset   int64 // смещение данных в несжатом архиве или -1
+}
+
+// streamIterator последовательно читает записи архива.
+// После Next данные текущей записи читаются через Read.
+type streamIterator interface {
+	io.Reader
+	Next() (*streamEntry, error)
+}
+
+// StreamFS предоставляет доступ только для чтения к архивам без оглавления,
+// в том числе сжатым. Такие архивы не поддерживают произвольный доступ,
+// поэтому при открытии записи архив читается заново с начала.
+type StreamFS struct {
+	kind        string
+	open        func() (io.ReadCloser, error)
+	compression Compression
+	iterate     func(io.Reader) streamIterator
+	entries     []*streamEntry
+	index       map[string]*streamEntry
+	tree        *archiveTree
+
+	// Для несжатых архивов с произвольным доступом записи читаются напрямую по смещению
+	ra     io.ReaderAt
+	closer io.Closer
+}
+
+// newStreamFS читает оглавление архива из потока, который возвращает open
+func newStreamFS(kind string, open func() (io.ReadCloser, error), compression Compression, iterate func(io.Reader) streamIterator) (*StreamFS, error) {
+	s := &StreamFS{
+		kind:        kind,
+		open:        open,
+		compression: compression,
+		iterate:     iterate,
+		index:       make(map[string]*streamEntry),
+		tree:        newArchiveTree(),
+	}
+
+	rc, it, err := s.reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	for i := 0; ; i++ {
+		entry, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения %s: %v", kind, err)
+		}
+
+		entry.name = cleanEntryName(entry.name)
+		entry.index = i
+		if entry.name == "" {
+			continue
+		}
+		s.entries = append(s.entries, entry)
+		s.index[entry.name] = entry
+		s.tree.add(entry.name, entry.info)
+	}
+
+	return s, nil
+}
+
+// reader открывает архив заново и возвращает итератор по записям
+func (s *StreamFS) reader() (io.Closer, streamIterator, error) {
+	rc, err := s.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := Decompress(rc, s.compression)
+	if err != nil {
+		rc.Close()
+		return nil, nil, fmt.Errorf("ошибка распаковки: %v", err)
+	}
+	return multiCloser{r, rc}, s.iterate(r), nil
+}
+
+func (s *StreamFS) lookup(name string) (*streamEntry, error) {
+	entry, ok := s.index[cleanEntryName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return entry, nil
+}
+
+func (s *StreamFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return s.tree.readDir(name)
+}
+
+func (s *StreamFS) Stat(name string) (os.FileInfo, error) {
+	return s.tree.stat(name)
+}
+
+func (s *StreamFS) Open(name string) (File, error) {
+	entry, err := s.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.hardlink {
+		if entry, err = s.lookup(entry.linkname); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.ra != nil && entry.offset >= 0 {
+		return &sectionFile{
+			SectionReader: io.NewSectionReader(s.ra, entry.offset, entry.info.Size()),
+			info:          entry.info,
+		}, nil
+	}
+
+	rc, it, err := s.reader()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i <= entry.index; i++ {
+		if _, err := it.Next(); err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("ошибка чтения %s: %v", s.kind, err)
+		}
+	}
+
+	return &readOnlyFile{
+		ReadCloser: readCloser{Reader: it, Closer: rc},
+		info:       entry.info,
+	}, nil
+}
+
+func (s *StreamFS) ReadLink(name string) (string, error) {
+	entry, err := s.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if entry.info.Mode()&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("%s не является символической ссылкой", name)
+	}
+	return entry.linkname, nil
+}
+
+func (s *StreamFS) archive() {}
+
+func (s *StreamFS) Create(name string) (File, error)          { return nil, ErrReadOnly }
+func (s *StreamFS) Mkdir(name string) error                   { return ErrReadOnly }
+func (s *StreamFS) Rename(oldname, newname string) error      { return ErrReadOnly }
+func (s *StreamFS) Remove(name string) error                  { return ErrReadOnly }
+func (s *StreamFS) Chmod(name string, mode os.FileMode) error { return ErrReadOnly }
+
+func (s *StreamFS) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+// sectionFile — запись архива, доступная по смещению; поддерживает произвольный доступ
+type sectionFile struct {
+	*io.SectionReader
+	info os.FileInfo
+}
+
+func (f *sectionFile) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (f *sectionFile) Stat() (os.FileInfo, error)  { return f.info, nil }
+func (f *sectionFile) Close() error                { return nil }
+
+// countingReader считает прочитанные байты
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// multiCloser закрывает несколько ресурсов по порядку
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var first error
+	for _, c := range mc {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// readCloser объединяет поток чтения с закрытием другого ресурса
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
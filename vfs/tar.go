@@ -0,0 +1,79 @@
+package vfs
+
+import (
+	"archive/tar"
+	"io"
+)
+
+// ===================== Tar-архивы =====================
+
+// OpenTar открывает tar-архив, расположенный в файловой системе fsys.
+// Тип сжатия определяется по расширению файла.
+func OpenTar(fsys FileSystem, name string) (*StreamFS, error) {
+	return openTar(fsys, name, tarCompression(name))
+}
+
+// openTar открывает tar-архив с известным сжатием. Несжатый архив, который
+// поддерживает произвольный доступ, читается по смещениям без повторного сканирования.
+func openTar(fsys FileSystem, name string, compression Compression) (*StreamFS, error) {
+	if compression == CompressionNone {
+		file, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		if ra, ok := file.(io.ReaderAt); ok {
+			if stat, err := file.Stat(); err == nil {
+				t, err := NewTarFS(sectionOpener(ra, 0, stat.Size()), CompressionNone)
+				if err != nil {
+					file.Close()
+					return nil, err
+				}
+				t.ra = ra
+				t.closer = file
+				return t, nil
+			}
+		}
+		file.Close()
+	}
+
+	open := func() (io.ReadCloser, error) {
+		return fsys.Open(name)
+	}
+	return NewTarFS(open, compression)
+}
+
+// NewTarFS читает оглавление tar-архива из потока, который возвращает open
+func NewTarFS(open func() (io.ReadCloser, error), compression Compression) (*StreamFS, error) {
+	return newStreamFS("tar", open, compression, func(r io.Reader) streamIterator {
+		counter := &countingReader{r: r}
+		return &tarIterator{Reader: tar.NewReader(counter), counter: counter}
+	})
+}
+
+// tarIterator читает записи tar-архива и запоминает смещения их данных
+type tarIterator struct {
+	*tar.Reader
+	counter *countingReader
+}
+
+func (t *tarIterator) Next() (*streamEntry, error) {
+	header, err := t.Reader.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	// tar.Reader не читает вперёд, поэтому после заголовка счётчик указывает на начало данных.
+	// У разреженных файлов данные хранятся не подряд.
+	offset := t.counter.n
+	if header.Typeflag == tar.TypeGNUSparse || len(header.PAXRecords["GNU.sparse.map"]) > 0 || header.PAXRecords["GNU.sparse.major"] != "" {
+		offset = -1
+	}
+
+	return &streamEntry{
+		name:     header.Name,
+		info:     header.FileInfo(),
+		linkname: header.Linkname,
+		hardlink: header.Typeflag == tar.TypeLink,
+		offset:   offset,
+	}, nil
+}
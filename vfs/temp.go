@@ -0,0 +1,63 @@
+package vfs
+
+import (
+	"io"
+	"os"
+)
+
+// ===================== Временные файлы =====================
+
+// tempFile удаляет временный файл при закрытии
+type tempFile struct {
+	*os.File
+}
+
+func (t *tempFile) Close() error {
+	err := t.File.Close()
+	os.Remove(t.File.Name())
+	return err
+}
+
+// spoolToTemp копирует поток во временный файл, чтобы получить произвольный доступ к данным
+func spoolToTemp(r io.Reader) (*tempFile, error) {
+	f, err := os.CreateTemp("", "filemanager-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp := &tempFile{File: f}
+
+	if _, err := io.Copy(f, r); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// openReaderAt открывает файл для произвольного доступа. Если файл его не поддерживает
+// (например, запись другого архива), он предварительно копируется во временный файл.
+func openReaderAt(fsys FileSystem, name string) (io.ReaderAt, int64, io.Closer, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var src File = file
+	if _, ok := file.(io.ReaderAt); !ok {
+		src, err = spoolToTemp(file)
+		file.Close()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+	}
+
+	stat, err := src.Stat()
+	if err != nil {
+		src.Close()
+		return nil, 0, nil, err
+	}
+	return src.(io.ReaderAt), stat.Size(), src, nil
+}
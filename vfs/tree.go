@@ -0,0 +1,89 @@
+package vfs
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// ===================== Оглавление архива =====================
+
+// archiveTree строит иерархию директорий по плоскому списку записей архива.
+// Директории, для которых в архиве нет отдельной записи, создаются автоматически.
+type archiveTree struct {
+	dirs map[string]map[string]os.FileInfo
+}
+
+func newArchiveTree() *archiveTree {
+	return &archiveTree{
+		dirs: map[string]map[string]os.FileInfo{"": {}},
+	}
+}
+
+// cleanEntryName приводит имя записи архива к виду "dir/file"; корень — пустая строка
+func cleanEntryName(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	return strings.TrimPrefix(name, "/")
+}
+
+// parentDir возвращает родительскую директорию записи; корень — пустая строка
+func parentDir(name string) string {
+	dir := path.Dir(name)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// add добавляет запись с очищенным именем name
+func (t *archiveTree) add(name string, info os.FileInfo) {
+	if name == "" {
+		return
+	}
+	parent := t.ensureDir(parentDir(name))
+	base := path.Base(name)
+	parent[base] = &renamedInfo{FileInfo: info, name: base}
+	if info.IsDir() {
+		if _, ok := t.dirs[name]; !ok {
+			t.dirs[name] = map[string]os.FileInfo{}
+		}
+	}
+}
+
+// ensureDir создаёт недостающие директории на пути name
+func (t *archiveTree) ensureDir(name string) map[string]os.FileInfo {
+	if children, ok := t.dirs[name]; ok {
+		return children
+	}
+	parent := t.ensureDir(parentDir(name))
+	base := path.Base(name)
+	if _, ok := parent[base]; !ok {
+		parent[base] = &fileInfo{name: base, mode: os.ModeDir | 0755}
+	}
+	children := map[string]os.FileInfo{}
+	t.dirs[name] = children
+	return children
+}
+
+func (t *archiveTree) readDir(name string) ([]os.FileInfo, error) {
+	children, ok := t.dirs[cleanEntryName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	infos := make([]os.FileInfo, 0, len(children))
+	for _, info := range children {
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (t *archiveTree) stat(name string) (os.FileInfo, error) {
+	name = cleanEntryName(name)
+	if name == "" {
+		return &fileInfo{name: "/", mode: os.ModeDir | 0755}, nil
+	}
+	if info, ok := t.dirs[parentDir(name)][path.Base(name)]; ok {
+		return info, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
@@ -0,0 +1,160 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ===================== Виртуальная файловая система =====================
+
+// ErrReadOnly возвращается при попытке изменить файловую систему, доступную только для чтения
+var ErrReadOnly = errors.New("файловая система доступна только для чтения")
+
+// File — открытый файл любой файловой системы
+type File interface {
+	io.ReadWriteCloser
+	Stat() (os.FileInfo, error)
+}
+
+// FileSystem — единый интерфейс для локальной файловой системы, SFTP и архивов
+type FileSystem interface {
+	ReadDir(name string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Open(name string) (File, error)
+	Create(name string) (File, error)
+	Mkdir(name string) error
+	Rename(oldname, newname string) error
+	// Remove удаляет файл или директорию вместе с содержимым
+	Remove(name string) error
+	ReadLink(name string) (string, error)
+	Chmod(name string, mode os.FileMode) error
+	Close() error
+}
+
+// archiveFS помечает файловые системы архивов в отличие от дисковых и удалённых
+type archiveFS interface {
+	archive()
+}
+
+func isArchiveFS(fsys FileSystem) bool {
+	_, ok := fsys.(archiveFS)
+	return ok
+}
+
+// ErrAppendUnsupported возвращается OpenAppend, если файловая система не умеет дописывать файлы
+var ErrAppendUnsupported = errors.New("дозапись файлов не поддерживается")
+
+// appendFS — файловые системы, которые умеют дописывать существующие файлы
+type appendFS interface {
+	OpenAppend(name string) (File, error)
+}
+
+// OpenAppend открывает существующий файл для записи в конец; используется
+// для докачки прерванных загрузок
+func OpenAppend(fsys FileSystem, name string) (File, error) {
+	if a, ok := fsys.(appendFS); ok {
+		return a.OpenAppend(name)
+	}
+	return nil, ErrAppendUnsupported
+}
+
+// ErrResumeUnsupported возвращается OpenFrom, если чтение файла нельзя начать с середины
+var ErrResumeUnsupported = errors.New("докачка не поддерживается")
+
+// offsetFS — файловые системы, которые сами умеют начинать передачу файла с середины
+type offsetFS interface {
+	OpenFrom(name string, offset int64) (File, error)
+}
+
+// OpenFrom открывает файл для чтения с позиции offset; используется для
+// докачки прерванных скачиваний. FTP и WebDAV продолжают передачу на стороне
+// сервера, остальные файловые системы — через Seek или ReadAt открытого файла.
+func OpenFrom(fsys FileSystem, name string, offset int64) (File, error) {
+	if o, ok := fsys.(offsetFS); ok {
+		return o.OpenFrom(name, offset)
+	}
+	file, err := fsys.Open(name)
+	if err != nil || offset == 0 {
+		return file, err
+	}
+
+	if seeker, ok := file.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err == nil {
+			return file, nil
+		}
+	}
+	info, err := file.Stat()
+	readerAt, ok := file.(io.ReaderAt)
+	if err != nil || !ok {
+		file.Close()
+		return nil, ErrResumeUnsupported
+	}
+	return &tailFile{SectionReader: io.NewSectionReader(readerAt, offset, info.Size()-offset), file: file}, nil
+}
+
+// headFS — файловые системы, которые умеют запрашивать только начало файла
+type headFS interface {
+	OpenHead(name string, limit int64) (File, error)
+}
+
+// OpenHead открывает файл, из которого нужны не больше limit первых байт
+// (превью). S3 запрашивает у сервера только этот диапазон; остальные файловые
+// системы открывают файл целиком, а чтение ограничивает вызывающий код.
+func OpenHead(fsys FileSystem, name string, limit int64) (File, error) {
+	if h, ok := fsys.(headFS); ok {
+		return h.OpenHead(name, limit)
+	}
+	return fsys.Open(name)
+}
+
+// tailFile читает открытый файл через ReadAt, начиная с заданного смещения
+type tailFile struct {
+	*io.SectionReader
+	file File
+}
+
+func (f *tailFile) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (f *tailFile) Stat() (os.FileInfo, error)  { return f.file.Stat() }
+func (f *tailFile) Close() error                { return f.file.Close() }
+
+// Replace переименовывает oldname в newname, заменяя существующий файл.
+// Не все файловые системы заменяют файл при Rename (WebDAV, SFTP без
+// posix-rename), поэтому файл назначения сначала отодвигается под временное
+// имя — но только если это не директория. Если и вторая попытка не удалась,
+// файл назначения возвращается на место.
+func Replace(fsys FileSystem, oldname, newname string) error {
+	err := fsys.Rename(oldname, newname)
+	if err == nil {
+		return nil
+	}
+	info, statErr := fsys.Stat(newname)
+	if statErr != nil || info.IsDir() {
+		return err
+	}
+	backup := newname + ".old"
+	if fsys.Rename(newname, backup) != nil {
+		return err
+	}
+	if err := fsys.Rename(oldname, newname); err != nil {
+		fsys.Rename(backup, newname)
+		return err
+	}
+	fsys.Remove(backup)
+	return nil
+}
+
+// timesFS — файловые системы, которые умеют менять время изменения файлов
+type timesFS interface {
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// Chtimes меняет время доступа и изменения файла; на файловых системах,
+// где время задать нельзя (FTP, WebDAV, S3), ничего не делает
+func Chtimes(fsys FileSystem, name string, atime, mtime time.Time) error {
+	if t, ok := fsys.(timesFS); ok {
+		return t.Chtimes(name, atime, mtime)
+	}
+	return nil
+}
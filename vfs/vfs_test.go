@@ -0,0 +1,93 @@
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noSeekFS отдаёт файлы, которые можно читать только через ReadAt
+type noSeekFS struct {
+	*LocalFS
+}
+
+func (n noSeekFS) Open(name string) (File, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := n.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sectionFile{SectionReader: io.NewSectionReader(bytes.NewReader(data), 0, info.Size()), info: info}, nil
+}
+
+func TestOpenFrom(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fsys := range map[string]FileSystem{"seek": NewLocalFS(), "readat": noSeekFS{NewLocalFS()}} {
+		t.Run(name, func(t *testing.T) {
+			r, err := OpenFrom(fsys, path, 4)
+			if err != nil {
+				t.Fatalf("OpenFrom: %v", err)
+			}
+			defer r.Close()
+			data, err := io.ReadAll(r)
+			if err != nil || string(data) != "456789" {
+				t.Fatalf("прочитано %q, %v", data, err)
+			}
+		})
+	}
+}
+
+// noReplaceFS не заменяет существующие файлы при Rename, а переименование
+// из failFrom всегда завершается ошибкой
+type noReplaceFS struct {
+	*LocalFS
+	failFrom string
+}
+
+func (n noReplaceFS) Rename(oldname, newname string) error {
+	if _, err := os.Stat(newname); err == nil || oldname == n.failFrom {
+		return &os.PathError{Op: "rename", Path: newname, Err: os.ErrExist}
+	}
+	return os.Rename(oldname, newname)
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "file.part"), filepath.Join(dir, "file")
+	write := func(name, data string) {
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(src, "new")
+	write(dst, "old")
+	if err := Replace(noReplaceFS{LocalFS: NewLocalFS()}, src, dst); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "new" {
+		t.Fatalf("файл не заменён: %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("остались лишние файлы: %v", entries)
+	}
+
+	// Если новый файл не переименовывается, старый возвращается на место
+	write(src, "new")
+	write(dst, "old")
+	if err := Replace(noReplaceFS{LocalFS: NewLocalFS(), failFrom: src}, src, dst); err == nil {
+		t.Fatal("ожидалась ошибка замены")
+	}
+	if data, err := os.ReadFile(dst); err != nil || string(data) != "old" {
+		t.Fatalf("файл назначения потерян: %q, %v", data, err)
+	}
+}
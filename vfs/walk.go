@@ -0,0 +1,38 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WalkFunc вызывается для каждого файла и директории при обходе
+type WalkFunc func(name string, info os.FileInfo) error
+
+// Walk обходит дерево root в fsys: сначала сама директория, затем её содержимое.
+func Walk(fsys FileSystem, root string, fn WalkFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		return err
+	}
+	return walk(fsys, root, info, fn)
+}
+
+func walk(fsys FileSystem, name string, info os.FileInfo, fn WalkFunc) error {
+	if err := fn(name, info); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+
+	children, err := fsys.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := walk(fsys, filepath.Join(name, child.Name()), child, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package vfs
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ===================== WebDAV =====================
+
+// WebDAVConfig описывает подключение к WebDAV-ресурсу
+type WebDAVConfig struct {
+	URL      string // адрес корня ресурса, например https://cloud/remote.php/dav/files/user
+	User     string
+	Password string
+}
+
+// WebDAVFS — файловая система на WebDAV-сервере. Пути отсчитываются от корня
+// ресурса; содержимое директорий читается запросом PROPFIND, файлы передаются
+// потоком через GET и PUT.
+type WebDAVFS struct {
+	base   *url.URL
+	user   string
+	pass   string
+	client *http.Client
+}
+
+// NewWebDAVFS проверяет доступность корня ресурса и возвращает файловую систему
+func NewWebDAVFS(config WebDAVConfig) (*WebDAVFS, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 15 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	d := &WebDAVFS{
+		base:   base,
+		user:   config.User,
+		pass:   config.Password,
+		client: &http.Client{Transport: transport},
+	}
+	info, err := d.Stat("/")
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s не является коллекцией WebDAV", config.URL)
+	}
+	return d, nil
+}
+
+// resolve возвращает URL ресурса по пути внутри файловой системы
+func (d *WebDAVFS) resolve(name string) *url.URL {
+	u := *d.base
+	u.Path = d.base.Path + path.Clean("/"+filepath.ToSlash(name))
+	u.RawPath = ""
+	return &u
+}
+
+func (d *WebDAVFS) request(method, name string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, d.resolve(name).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if d.user != "" || d.pass != "" {
+		req.SetBasicAuth(d.user, d.pass)
+	}
+	return d.client.Do(req)
+}
+
+// statusError переводит ответ сервера в ошибку; 404 становится os.ErrNotExist,
+// 412 (назначение MOVE уже существует) — os.ErrExist
+func statusError(method, name string, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &os.PathError{Op: strings.ToLower(method), Path: name, Err: os.ErrNotExist}
+	case http.StatusPreconditionFailed:
+		return &os.PathError{Op: strings.ToLower(method), Path: name, Err: os.ErrExist}
+	case http.StatusUnauthorized:
+		return fmt.Errorf("%s %s: неверный логин или пароль", method, name)
+	default:
+		return fmt.Errorf("%s %s: %s", method, name, resp.Status)
+	}
+}
+
+// ===================== PROPFIND =====================
+
+type davMultistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"DAV: href"`
+	Propstats []davPropstat `xml:"DAV: propstat"`
+}
+
+type davPropstat struct {
+	Status string  `xml:"DAV: status"`
+	Prop   davProp `xml:"DAV: prop"`
+}
+
+type davProp struct {
+	ContentLength string `xml:"DAV: getcontentlength"`
+	LastModified  string `xml:"DAV: getlastmodified"`
+	ResourceType  struct {
+		Collection *struct{} `xml:"DAV: collection"`
+	} `xml:"DAV: resourcetype"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// davEntry — разобранный элемент ответа PROPFIND
+type davEntry struct {
+	path string
+	info *fileInfo
+}
+
+func (d *WebDAVFS) propfind(name, depth string) ([]davEntry, error) {
+	header := http.Header{
+		"Depth":        {depth},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	}
+	resp, err := d.request("PROPFIND", name, strings.NewReader(propfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("PROPFIND", name, resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("PROPFIND %s: %v", name, err)
+	}
+
+	entries := make([]davEntry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		// Путь элемента относительно корня ресурса
+		rel := strings.TrimPrefix(path.Clean("/"+href.Path), d.base.Path)
+		rel = path.Clean("/" + rel)
+
+		info := &fileInfo{name: path.Base(rel), mode: 0644}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") && ps.Status != "" {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				info.mode = os.ModeDir | 0755
+			}
+			if size, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+				info.size = size
+			}
+			if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				info.modTime = t
+			}
+		}
+		if info.IsDir() {
+			info.size = 0
+		}
+		entries = append(entries, davEntry{path: rel, info: info})
+	}
+	return entries, nil
+}
+
+func (d *WebDAVFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := d.propfind(name, "1")
+	if err != nil {
+		return nil, err
+	}
+
+	self := path.Clean("/" + filepath.ToSlash(name))
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.path == self {
+			continue
+		}
+		infos = append(infos, entry.info)
+	}
+	return infos, nil
+}
+
+func (d *WebDAVFS) Stat(name string) (os.FileInfo, error) {
+	entries, err := d.propfind(name, "0")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	info := entries[0].info
+	if path.Clean("/"+filepath.ToSlash(name)) == "/" {
+		info.name = "/"
+	}
+	return info, nil
+}
+
+// ===================== Передача файлов =====================
+
+func (d *WebDAVFS) Open(name string) (File, error) {
+	return d.OpenFrom(name, 0)
+}
+
+// OpenFrom продолжает чтение файла с позиции offset запросом GET с заголовком
+// Range. Если сервер отдаёт файл целиком, возвращает ErrResumeUnsupported.
+func (d *WebDAVFS) OpenFrom(name string, offset int64) (File, error) {
+	info, err := d.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s является директорией", name)
+	}
+	// Файл уже передан целиком: пустой диапазон сервер отклонил бы с кодом 416
+	if offset > 0 && offset >= info.Size() {
+		return &readOnlyFile{ReadCloser: io.NopCloser(strings.NewReader("")), info: info}, nil
+	}
+
+	var header http.Header
+	want := http.StatusOK
+	if offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+		want = http.StatusPartialContent
+	}
+	resp, err := d.request(http.MethodGet, name, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != want {
+		resp.Body.Close()
+		if offset > 0 && resp.StatusCode == http.StatusOK {
+			return nil, ErrResumeUnsupported
+		}
+		return nil, statusError("GET", name, resp)
+	}
+	return &readOnlyFile{ReadCloser: resp.Body, info: info}, nil
+}
+
+func (d *WebDAVFS) Create(name string) (File, error) {
+	return newPipeFile(name, func(r io.Reader) error {
+		resp, err := d.request(http.MethodPut, name, r, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return statusError("PUT", name, resp)
+		}
+		return nil
+	}), nil
+}
+
+// ===================== Операции над ресурсами =====================
+
+// simple выполняет запрос без тела и проверяет код ответа
+func (d *WebDAVFS) simple(method, name string, header http.Header) error {
+	resp, err := d.request(method, name, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(method, name, resp)
+	}
+	return nil
+}
+
+func (d *WebDAVFS) Mkdir(name string) error {
+	return d.simple("MKCOL", name, nil)
+}
+
+// Rename не заменяет существующий ресурс: при Overwrite: T сервер удалил бы
+// коллекцию назначения вместе с содержимым. Замену файла выполняет Replace.
+func (d *WebDAVFS) Rename(oldname, newname string) error {
+	return d.simple("MOVE", oldname, http.Header{
+		"Destination": {d.resolve(newname).String()},
+		"Overwrite":   {"F"},
+	})
+}
+
+// Remove удаляет файл или коллекцию целиком: DELETE в WebDAV рекурсивен
+func (d *WebDAVFS) Remove(name string) error {
+	return d.simple(http.MethodDelete, name, nil)
+}
+
+func (d *WebDAVFS) ReadLink(name string) (string, error) {
+	return "", fmt.Errorf("символические ссылки не поддерживаются в WebDAV")
+}
+
+func (d *WebDAVFS) Chmod(name string, mode os.FileMode) error {
+	return fmt.Errorf("изменение прав не поддерживается в WebDAV")
+}
+
+func (d *WebDAVFS) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,212 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/webdav"
+)
+
+// startWebDAVServer запускает WebDAV-сервер с ресурсом в памяти под /dav
+func startWebDAVServer(t *testing.T) *WebDAVFS {
+	t.Helper()
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: webdav.NewMemLS(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	fsys, err := NewWebDAVFS(WebDAVConfig{URL: server.URL + "/dav/", User: "user", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewWebDAVFS: %v", err)
+	}
+	t.Cleanup(func() { fsys.Close() })
+	return fsys
+}
+
+func writeDAVFile(t *testing.T, fsys FileSystem, name, data string) {
+	t.Helper()
+	w, err := fsys.Create(name)
+	if err != nil {
+		t.Fatalf("Create %s: %v", name, err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close %s: %v", name, err)
+	}
+}
+
+func readDAVFile(t *testing.T, fsys FileSystem, name string) string {
+	t.Helper()
+	r, err := fsys.Open(name)
+	if err != nil {
+		t.Fatalf("Open %s: %v", name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWebDAVFS(t *testing.T) {
+	fsys := startWebDAVServer(t)
+
+	if err := fsys.Mkdir("/docs"); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	writeDAVFile(t, fsys, "/docs/a.txt", "first")
+
+	infos, err := fsys.ReadDir("/")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "docs" || !infos[0].IsDir() {
+		t.Fatalf("неверный список: %v", infos)
+	}
+	info, err := fsys.Stat("/docs/a.txt")
+	if err != nil || info.Size() != 5 || info.IsDir() {
+		t.Fatalf("Stat: %v, %v", info, err)
+	}
+	if data := readDAVFile(t, fsys, "/docs/a.txt"); data != "first" {
+		t.Fatalf("прочитано %q", data)
+	}
+
+	if _, err := fsys.Stat("/missing"); !os.IsNotExist(err) {
+		t.Fatalf("ожидалась os.ErrNotExist, получено %v", err)
+	}
+	if _, err := fsys.Open("/docs"); err == nil {
+		t.Fatal("директория открылась как файл")
+	}
+}
+
+func TestWebDAVRenameKeepsExisting(t *testing.T) {
+	fsys := startWebDAVServer(t)
+	writeDAVFile(t, fsys, "/file", "content")
+	if err := fsys.Mkdir("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	writeDAVFile(t, fsys, "/dir/inner.txt", "keep me")
+	writeDAVFile(t, fsys, "/other", "old")
+
+	// Перемещение на существующую директорию не должно удалять её содержимое
+	if err := fsys.Rename("/file", "/dir"); !os.IsExist(err) {
+		t.Fatalf("ожидалась os.ErrExist, получено %v", err)
+	}
+	if data := readDAVFile(t, fsys, "/dir/inner.txt"); data != "keep me" {
+		t.Fatalf("содержимое директории изменилось: %q", data)
+	}
+	if err := fsys.Rename("/file", "/other"); !os.IsExist(err) {
+		t.Fatalf("ожидалась os.ErrExist для файла, получено %v", err)
+	}
+	if data := readDAVFile(t, fsys, "/other"); data != "old" {
+		t.Fatalf("файл назначения изменился: %q", data)
+	}
+}
+
+func TestWebDAVReplace(t *testing.T) {
+	fsys := startWebDAVServer(t)
+	writeDAVFile(t, fsys, "/file.part", "new content")
+	writeDAVFile(t, fsys, "/file", "old")
+
+	if err := Replace(fsys, "/file.part", "/file"); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if data := readDAVFile(t, fsys, "/file"); data != "new content" {
+		t.Fatalf("прочитано %q", data)
+	}
+	if _, err := fsys.Stat("/file.part"); !os.IsNotExist(err) {
+		t.Fatalf("исходный файл остался: %v", err)
+	}
+	if _, err := fsys.Stat("/file.old"); !os.IsNotExist(err) {
+		t.Fatalf("отодвинутый файл назначения остался: %v", err)
+	}
+
+	// Директория назначения не заменяется
+	if err := fsys.Mkdir("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	writeDAVFile(t, fsys, "/dir/inner.txt", "keep me")
+	writeDAVFile(t, fsys, "/dir.part", "file")
+	if err := Replace(fsys, "/dir.part", "/dir"); err == nil {
+		t.Fatal("директория назначения не должна заменяться")
+	}
+	if data := readDAVFile(t, fsys, "/dir/inner.txt"); data != "keep me" {
+		t.Fatalf("содержимое директории изменилось: %q", data)
+	}
+}
+
+func TestWebDAVRemoveCollection(t *testing.T) {
+	fsys := startWebDAVServer(t)
+	if err := fsys.Mkdir("/dir"); err != nil {
+		t.Fatal(err)
+	}
+	writeDAVFile(t, fsys, "/dir/inner.txt", "x")
+
+	if err := fsys.Remove("/dir"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := fsys.Stat("/dir"); !os.IsNotExist(err) {
+		t.Fatalf("коллекция не удалена: %v", err)
+	}
+}
+
+func TestWebDAVBadPassword(t *testing.T) {
+	fsys := startWebDAVServer(t)
+	_, err := NewWebDAVFS(WebDAVConfig{URL: fsys.base.Scheme + "://" + fsys.base.Host + "/dav", User: "user", Password: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "неверный логин или пароль") {
+		t.Fatalf("ожидалась ошибка входа, получено %v", err)
+	}
+}
+
+func TestWebDAVResume(t *testing.T) {
+	fsys := startWebDAVServer(t)
+	writeDAVFile(t, fsys, "/data.bin", "0123456789")
+
+	for offset, want := range map[int64]string{0: "0123456789", 6: "6789", 10: ""} {
+		r, err := OpenFrom(fsys, "/data.bin", offset)
+		if err != nil {
+			t.Fatalf("OpenFrom %d: %v", offset, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(data) != want {
+			t.Fatalf("с позиции %d прочитано %q, %v", offset, data, err)
+		}
+	}
+}
+
+func TestWebDAVResumeUnsupported(t *testing.T) {
+	// Сервер без поддержки Range всегда отдаёт файл целиком
+	handler := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Range")
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	fsys, err := NewWebDAVFS(WebDAVConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewWebDAVFS: %v", err)
+	}
+	writeDAVFile(t, fsys, "/data.bin", "0123456789")
+	if _, err := OpenFrom(fsys, "/data.bin", 6); !errors.Is(err, ErrResumeUnsupported) {
+		t.Fatalf("ожидалась ErrResumeUnsupported, получено %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package vfs
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ===================== Zip-архивы =====================
+
+// ZipFS предоставляет доступ к содержимому zip-архива. Если архив лежит
+// в «настоящей» файловой системе, его записи можно изменять (см. zipedit.go).
+type ZipFS struct {
+	reader  *zip.Reader
+	closer  io.Closer
+	entries map[string]*zip.File
+	tree    *archiveTree
+
+	source     FileSystem // файловая система, в которой лежит архив
+	sourceName string
+}
+
+// OpenZip открывает zip-архив, расположенный в файловой системе fsys
+func OpenZip(fsys FileSystem, name string) (*ZipFS, error) {
+	reader, closer, err := openZipReader(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	z := NewZipFS(reader, closer)
+	if !isArchiveFS(fsys) {
+		z.source = fsys
+		z.sourceName = name
+	}
+	return z, nil
+}
+
+// openZipReader открывает zip-архив для чтения
+func openZipReader(fsys FileSystem, name string) (*zip.Reader, io.Closer, error) {
+	ra, size, closer, err := openReaderAt(fsys, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := zip.NewReader(ra, size)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return reader, closer, nil
+}
+
+// NewZipFS создаёт файловую систему поверх уже открытого zip.Reader.
+// closer закрывается вместе с файловой системой и может быть nil.
+func NewZipFS(reader *zip.Reader, closer io.Closer) *ZipFS {
+	z := &ZipFS{}
+	z.load(reader, closer)
+	return z
+}
+
+// load строит оглавление архива
+func (z *ZipFS) load(reader *zip.Reader, closer io.Closer) {
+	z.reader = reader
+	z.closer = closer
+	z.entries = make(map[string]*zip.File, len(reader.File))
+	z.tree = newArchiveTree()
+	for _, f := range reader.File {
+		name := cleanEntryName(f.Name)
+		z.entries[name] = f
+		z.tree.add(name, f.FileInfo())
+	}
+}
+
+func (z *ZipFS) archive() {}
+
+func (z *ZipFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return z.tree.readDir(name)
+}
+
+func (z *ZipFS) lookup(name string) (*zip.File, error) {
+	f, ok := z.entries[cleanEntryName(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return f, nil
+}
+
+func (z *ZipFS) Stat(name string) (os.FileInfo, error) {
+	return z.tree.stat(name)
+}
+
+func (z *ZipFS) Open(name string) (File, error) {
+	f, err := z.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyFile{ReadCloser: rc, info: f.FileInfo()}, nil
+}
+
+func (z *ZipFS) ReadLink(name string) (string, error) {
+	f, err := z.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if f.Mode()&os.ModeSymlink == 0 {
+		return "", fmt.Errorf("%s не является символической ссылкой", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	target, err := io.ReadAll(rc)
+	if err != nil {
+		return "", err
+	}
+	return string(target), nil
+}
+
+func (z *ZipFS) Close() error {
+	if z.closer != nil {
+		return z.closer.Close()
+	}
+	return nil
+}
+
+// readOnlyFile оборачивает поток чтения записи архива
+type readOnlyFile struct {
+	io.ReadCloser
+	info os.FileInfo
+}
+
+func (f *readOnlyFile) Write(p []byte) (int, error) { return 0, ErrReadOnly }
+func (f *readOnlyFile) Stat() (os.FileInfo, error)  { return f.info, nil }
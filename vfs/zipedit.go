@@ -0,0 +1,263 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ===================== Изменение zip-архивов =====================
+
+// zipEntry — запись нового архива: копия существующей записи или новые данные
+type zipEntry struct {
+	header zip.FileHeader
+	from   *zip.File
+	data   []byte
+}
+
+// current возвращает записи архива в исходном порядке
+func (z *ZipFS) current() []zipEntry {
+	entries := make([]zipEntry, 0, len(z.reader.File))
+	for _, f := range z.reader.File {
+		entries = append(entries, zipEntry{header: f.FileHeader, from: f})
+	}
+	return entries
+}
+
+// hasPrefix проверяет, совпадает ли запись с name или лежит внутри неё
+func hasPrefix(entry, name string) bool {
+	return entry == name || strings.HasPrefix(entry, name+"/")
+}
+
+// rewrite записывает архив с новым набором записей во временный файл рядом
+// с исходным и атомарно заменяет им исходный архив
+func (z *ZipFS) rewrite(entries []zipEntry) error {
+	if z.source == nil {
+		return ErrReadOnly
+	}
+
+	dir, base := filepath.Split(z.sourceName)
+	tmpName := filepath.Join(dir, "."+base+".tmp")
+	out, err := z.source.Create(tmpName)
+	if err != nil {
+		return err
+	}
+
+	err = writeZipEntries(out, z.reader.Comment, entries)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// Новый архив получает права исходного; файловые системы без прав
+		// доступа (S3) Chmod не поддерживают, архив тогда заменяется как есть
+		if info, statErr := z.source.Stat(z.sourceName); statErr == nil {
+			z.source.Chmod(tmpName, info.Mode().Perm())
+		}
+		err = Replace(z.source, tmpName, z.sourceName)
+	}
+	if err != nil {
+		z.source.Remove(tmpName)
+		return err
+	}
+
+	reader, closer, err := openZipReader(z.source, z.sourceName)
+	if err != nil {
+		return err
+	}
+	old := z.closer
+	z.load(reader, closer)
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func writeZipEntries(out io.Writer, comment string, entries []zipEntry) error {
+	zw := zip.NewWriter(out)
+	if err := zw.SetComment(comment); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		header := e.header
+		if e.from == nil {
+			w, err := zw.CreateHeader(&header)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(e.data); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Неизменённые данные копируются без повторного сжатия
+		w, err := zw.CreateRaw(&header)
+		if err != nil {
+			return err
+		}
+		r, err := e.from.OpenRaw()
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// newZipHeader создаёт заголовок новой записи
+func newZipHeader(name string, mode os.FileMode) zip.FileHeader {
+	header := zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	if mode.IsDir() {
+		header.Name += "/"
+		header.Method = zip.Store
+	}
+	header.SetMode(mode)
+	return header
+}
+
+// Create возвращает файл, содержимое которого попадает в архив при закрытии
+func (z *ZipFS) Create(name string) (File, error) {
+	if z.source == nil {
+		return nil, ErrReadOnly
+	}
+	name = cleanEntryName(name)
+	if info, err := z.tree.stat(name); err == nil && info.IsDir() {
+		return nil, &os.PathError{Op: "create", Path: name, Err: os.ErrExist}
+	}
+	return &zipWriteFile{zfs: z, name: name}, nil
+}
+
+func (z *ZipFS) Mkdir(name string) error {
+	if z.source == nil {
+		return ErrReadOnly
+	}
+	name = cleanEntryName(name)
+	if _, err := z.tree.stat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	return z.rewrite(append(z.current(), zipEntry{header: newZipHeader(name, os.ModeDir|0755)}))
+}
+
+// Rename переименовывает запись вместе со всеми вложенными записями
+func (z *ZipFS) Rename(oldname, newname string) error {
+	if z.source == nil {
+		return ErrReadOnly
+	}
+	oldname, newname = cleanEntryName(oldname), cleanEntryName(newname)
+	if _, err := z.tree.stat(oldname); err != nil {
+		return err
+	}
+	if _, err := z.tree.stat(newname); err == nil {
+		return &os.PathError{Op: "rename", Path: newname, Err: os.ErrExist}
+	}
+	if hasPrefix(newname, oldname) {
+		return &os.PathError{Op: "rename", Path: newname, Err: os.ErrInvalid}
+	}
+
+	entries := z.current()
+	for i, e := range entries {
+		name := cleanEntryName(e.header.Name)
+		if !hasPrefix(name, oldname) {
+			continue
+		}
+		entries[i].header.Name = newname + strings.TrimPrefix(name, oldname)
+		if e.from.FileInfo().IsDir() {
+			entries[i].header.Name += "/"
+		}
+	}
+	return z.rewrite(entries)
+}
+
+// Remove удаляет запись вместе со всеми вложенными записями
+func (z *ZipFS) Remove(name string) error {
+	if z.source == nil {
+		return ErrReadOnly
+	}
+	name = cleanEntryName(name)
+	if _, err := z.tree.stat(name); err != nil || name == "" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+
+	var entries []zipEntry
+	for _, e := range z.current() {
+		if !hasPrefix(cleanEntryName(e.header.Name), name) {
+			entries = append(entries, e)
+		}
+	}
+	return z.rewrite(entries)
+}
+
+func (z *ZipFS) Chmod(name string, mode os.FileMode) error {
+	if z.source == nil {
+		return ErrReadOnly
+	}
+	f, err := z.lookup(name)
+	if err != nil {
+		return err
+	}
+
+	entries := z.current()
+	for i, e := range entries {
+		if e.from == f {
+			entries[i].header.SetMode(f.Mode()&^os.ModePerm | mode.Perm())
+		}
+	}
+	return z.rewrite(entries)
+}
+
+// zipWriteFile накапливает содержимое новой записи и перезаписывает архив при закрытии
+type zipWriteFile struct {
+	zfs  *ZipFS
+	name string
+	buf  bytes.Buffer
+}
+
+func (f *zipWriteFile) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (f *zipWriteFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+
+func (f *zipWriteFile) Stat() (os.FileInfo, error) {
+	return &fileInfo{name: filepath.Base(f.name), size: int64(f.buf.Len()), mode: 0644, modTime: time.Now()}, nil
+}
+
+// Close заменяет существующую запись на её месте, сохраняя права, внешние
+// атрибуты, метод сжатия и комментарий; новая запись добавляется в конец
+func (f *zipWriteFile) Close() error {
+	entries := f.zfs.current()
+	for i, e := range entries {
+		if cleanEntryName(e.header.Name) == f.name {
+			entries[i] = zipEntry{header: replacedZipHeader(e.header), data: f.buf.Bytes()}
+			return f.zfs.rewrite(entries)
+		}
+	}
+	entries = append(entries, zipEntry{header: newZipHeader(f.name, 0644), data: f.buf.Bytes()})
+	return f.zfs.rewrite(entries)
+}
+
+// replacedZipHeader готовит заголовок существующей записи для новых данных:
+// размеры и CRC пересчитываются при записи, а дополнительные поля со старыми
+// временами заменяет zip.Writer
+func replacedZipHeader(old zip.FileHeader) zip.FileHeader {
+	header := old
+	header.Modified = time.Now()
+	header.Extra = nil
+	header.Flags &^= 0x1 // новые данные не зашифрованы
+	header.CRC32 = 0
+	header.CompressedSize, header.CompressedSize64 = 0, 0
+	header.UncompressedSize, header.UncompressedSize64 = 0, 0
+	// Записывать zip.Writer умеет только Store и Deflate
+	if header.Method != zip.Store {
+		header.Method = zip.Deflate
+	}
+	return header
+}
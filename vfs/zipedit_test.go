@@ -0,0 +1,294 @@
+package vfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestZip создаёт архив с записями name → содержимое в порядке files
+func writeTestZip(t *testing.T, path string, files [][2]string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	zw := zip.NewWriter(out)
+	for _, file := range files {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// openTestZip открывает архив на локальном диске для изменения
+func openTestZip(t *testing.T, path string) *ZipFS {
+	t.Helper()
+	z, err := OpenZip(NewLocalFS(), path)
+	if err != nil {
+		t.Fatalf("OpenZip: %v", err)
+	}
+	t.Cleanup(func() { z.Close() })
+	return z
+}
+
+func TestZipRewriteKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	writeTestZip(t, path, [][2]string{{"a.txt", "a"}})
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	z := openTestZip(t, path)
+	if err := z.Mkdir("dir"); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("права архива изменились: %v", info.Mode().Perm())
+	}
+}
+
+func TestZipOverwriteKeepsHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	header := &zip.FileHeader{Name: "run.sh", Method: zip.Store, Comment: "скрипт"}
+	header.SetMode(0755)
+	header.ExternalAttrs |= 0x20 // атрибут архива DOS
+	w, err := zw.CreateHeader(header)
+	if err == nil {
+		_, err = w.Write([]byte("old"))
+	}
+	if err == nil {
+		_, err = zw.Create("b.txt")
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	out.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := openTestZip(t, path)
+	f, err := z.Create("run.sh")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	f.Write([]byte("#!/bin/sh"))
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 2 || r.File[0].Name != "run.sh" {
+		t.Fatalf("запись не заменена на месте: %d записей", len(r.File))
+	}
+	got := r.File[0]
+	if got.Mode() != 0755 || got.ExternalAttrs != header.ExternalAttrs || got.Method != zip.Store || got.Comment != "скрипт" {
+		t.Fatalf("заголовок не сохранён: mode %v, attrs %#x, method %d, comment %q", got.Mode(), got.ExternalAttrs, got.Method, got.Comment)
+	}
+	if data := readZipEntry(t, got); data != "#!/bin/sh" {
+		t.Fatalf("новое содержимое не записано: %q", data)
+	}
+}
+
+// readZipEntry читает содержимое записи архива
+func readZipEntry(t *testing.T, f *zip.File) string {
+	t.Helper()
+	r, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// rawZipEntries возвращает сжатые данные записей архива в исходном порядке
+func rawZipEntries(t *testing.T, path string) ([]string, map[string][]byte) {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	raw := make(map[string][]byte)
+	for _, f := range r.File {
+		rr, err := f.OpenRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		raw[f.Name] = data
+	}
+	return names, raw
+}
+
+func TestZipEdit(t *testing.T) {
+	files := [][2]string{
+		{"keep.txt", strings.Repeat("несжатые данные ", 100)},
+		{"dir/a.txt", "a"},
+		{"dir/sub/b.txt", "b"},
+		{"old.txt", "old"},
+	}
+	tests := []struct {
+		name    string
+		edit    func(z *ZipFS) error
+		want    []string
+		changed string // запись с новым содержимым
+	}{
+		{
+			name: "создание",
+			edit: func(z *ZipFS) error {
+				f, err := z.Create("new.txt")
+				if err != nil {
+					return err
+				}
+				f.Write([]byte("new"))
+				return f.Close()
+			},
+			want:    []string{"keep.txt", "dir/a.txt", "dir/sub/b.txt", "old.txt", "new.txt"},
+			changed: "new.txt",
+		},
+		{
+			name: "переименование",
+			edit: func(z *ZipFS) error { return z.Rename("dir", "moved") },
+			want: []string{"keep.txt", "moved/a.txt", "moved/sub/b.txt", "old.txt"},
+		},
+		{
+			name: "удаление директории",
+			edit: func(z *ZipFS) error { return z.Remove("dir") },
+			want: []string{"keep.txt", "old.txt"},
+		},
+		{
+			name: "перезапись",
+			edit: func(z *ZipFS) error {
+				f, err := z.Create("old.txt")
+				if err != nil {
+					return err
+				}
+				f.Write([]byte("replaced"))
+				return f.Close()
+			},
+			want:    []string{"keep.txt", "dir/a.txt", "dir/sub/b.txt", "old.txt"},
+			changed: "old.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive.zip")
+			writeTestZip(t, path, files)
+			_, before := rawZipEntries(t, path)
+
+			if err := tt.edit(openTestZip(t, path)); err != nil {
+				t.Fatalf("изменение архива: %v", err)
+			}
+
+			names, after := rawZipEntries(t, path)
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("записи архива: %v, ожидалось %v", names, tt.want)
+			}
+			// Нетронутые записи копируются без перепаковки, байт в байт
+			for _, name := range names {
+				original := name
+				if strings.HasPrefix(name, "moved/") {
+					original = "dir/" + strings.TrimPrefix(name, "moved/")
+				}
+				if name == tt.changed {
+					continue
+				}
+				if !bytes.Equal(after[name], before[original]) {
+					t.Fatalf("запись %s изменилась", name)
+				}
+			}
+			if tt.changed != "" {
+				z := openTestZip(t, path)
+				f, err := z.Open(tt.changed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := io.ReadAll(f)
+				f.Close()
+				if want := map[string]string{"new.txt": "new", "old.txt": "replaced"}[tt.changed]; string(data) != want {
+					t.Fatalf("содержимое %s: %q", tt.changed, data)
+				}
+			}
+		})
+	}
+}
+
+// failingRenameFS не может заменить архив: Rename всегда завершается ошибкой
+type failingRenameFS struct {
+	*LocalFS
+}
+
+func (failingRenameFS) Rename(oldname, newname string) error {
+	return &os.PathError{Op: "rename", Path: newname, Err: os.ErrPermission}
+}
+
+func TestZipFailedRewrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "archive.zip")
+	writeTestZip(t, path, [][2]string{{"a.txt", "a"}})
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := OpenZip(failingRenameFS{NewLocalFS()}, path)
+	if err != nil {
+		t.Fatalf("OpenZip: %v", err)
+	}
+	defer z.Close()
+	if err := z.Mkdir("dir"); err == nil {
+		t.Fatal("ожидалась ошибка замены архива")
+	}
+
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, original) {
+		t.Fatalf("исходный архив повреждён: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("рядом с архивом остались файлы: %v", entries)
+	}
+	// Архив по-прежнему открыт и читается
+	if _, err := z.Stat("a.txt"); err != nil {
+		t.Fatalf("Stat после неудачной замены: %v", err)
+	}
+}